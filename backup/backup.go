@@ -0,0 +1,328 @@
+package backup
+
+import (
+	"bytes"
+	"comic-summaries/comicio"
+	"comic-summaries/entity"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion はスナップショットのデータ形式のバージョンです。
+// entity.Comicの構造を変更した場合は値を上げ、Restoreで読み込めるか判定します。
+const SchemaVersion = 1
+
+const (
+	// snapshotTimeFormat はスナップショットのディレクトリ名に付ける作成日時です。同じ秒に続けて作成しても重ならないようミリ秒まで含めます。
+	snapshotTimeFormat = "20060102T150405.000Z"
+	manifestFile       = "manifest.json"
+	dataFile           = "comics.ndjson.gz"
+)
+
+// Manifest はスナップショットの内容を記述します。
+type Manifest struct {
+	SchemaVersion int       `json:"schema_version"`
+	Table         string    `json:"table"`
+	ItemCount     int       `json:"item_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	DataFile      string    `json:"data_file"`
+	SHA256        string    `json:"sha256"`
+}
+
+// Snapshot はディレクトリに保存されたスナップショットです。
+type Snapshot struct {
+	Dir      string
+	Manifest Manifest
+}
+
+// Create はテーブル全件をgzip圧縮したNDJSONとしてdir配下の新しいスナップショットに書き出します。
+// Loadで読み込めないスナップショットを残さないよう、テーブルにLoadの検証を通らないアイテムがあればエラーにします。
+func Create(ctx context.Context, api comicio.ScanAPI, tableName string, dir string, now time.Time) (*Snapshot, error) {
+	comics, err := comicio.ScanComics(ctx, api, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := comicio.Validate(comics); err != nil {
+		return nil, fmt.Errorf("table %s has items that could not be restored, fix them before taking a snapshot:\n%w", tableName, err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := comicio.WriteNDJSON(gz, comics); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+
+	now = now.UTC()
+	snapshotDir := filepath.Join(dir, tableName+"-"+now.Format(snapshotTimeFormat))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	// 同じ時刻のスナップショットを上書きしないよう、既存のディレクトリはエラーにする
+	if err := os.Mkdir(snapshotDir, 0o755); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("snapshot %s already exists", snapshotDir)
+		}
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(snapshotDir, dataFile), buf.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+
+	manifest := Manifest{
+		SchemaVersion: SchemaVersion,
+		Table:         tableName,
+		ItemCount:     len(comics),
+		CreatedAt:     now,
+		DataFile:      dataFile,
+		SHA256:        hex.EncodeToString(sum[:]),
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// マニフェストは最後に書き込み、途中で失敗したスナップショットを読み込まないようにする
+	if err := os.WriteFile(filepath.Join(snapshotDir, manifestFile), data, 0o644); err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{Dir: snapshotDir, Manifest: manifest}, nil
+}
+
+// List はdir配下のマニフェストを持つスナップショットを作成日時の新しい順に返します。
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshotDir := filepath.Join(dir, entry.Name())
+		manifest, err := readManifest(snapshotDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // 書き込み途中のスナップショット
+			}
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Dir: snapshotDir, Manifest: *manifest})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Manifest.CreatedAt.After(snapshots[j].Manifest.CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Latest はdir配下で最も新しいスナップショットを返します。tableNameが空でなければそのテーブルのものに限ります。
+func Latest(dir string, tableName string) (*Snapshot, error) {
+	snapshots, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if tableName == "" || snapshot.Manifest.Table == tableName {
+			return &snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("no snapshot found in %s", dir)
+}
+
+// Prune はテーブルごとに新しい順でkeep件を残し、それより古いスナップショットを削除します。
+// maxAgeが0より大きければ、keep件の範囲内でもそれより古いものを削除します。ただし最新の1件は常に残します。
+func Prune(dir string, keep int, maxAge time.Duration, now time.Time) ([]Snapshot, error) {
+	snapshots, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Snapshot
+	counts := make(map[string]int)
+	for _, snapshot := range snapshots {
+		table := snapshot.Manifest.Table
+		counts[table]++
+		expired := maxAge > 0 && now.Sub(snapshot.Manifest.CreatedAt) > maxAge
+		if counts[table] == 1 || (counts[table] <= keep && !expired) {
+			continue
+		}
+		if err := os.RemoveAll(snapshot.Dir); err != nil {
+			return removed, err
+		}
+		removed = append(removed, snapshot)
+	}
+
+	return removed, nil
+}
+
+// Load はスナップショットのチェックサム・件数・スキーマバージョンを検証して漫画データを読み込みます。
+func Load(snapshotDir string) ([]entity.Comic, *Manifest, error) {
+	manifest, err := readManifest(snapshotDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest.SchemaVersion != SchemaVersion {
+		return nil, nil, fmt.Errorf("unsupported schema version %d (expected %d)", manifest.SchemaVersion, SchemaVersion)
+	}
+	if strings.ContainsAny(manifest.DataFile, `/\`) {
+		return nil, nil, fmt.Errorf("invalid data file name %q", manifest.DataFile)
+	}
+
+	data, err := os.ReadFile(filepath.Join(snapshotDir, manifest.DataFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != manifest.SHA256 {
+		return nil, nil, fmt.Errorf("checksum mismatch: manifest has %s, data file has %s", manifest.SHA256, got)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gz.Close()
+
+	comics, err := comicio.Read(gz, comicio.FormatNDJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(comics) != manifest.ItemCount {
+		return nil, nil, fmt.Errorf("item count mismatch: manifest has %d, data file has %d", manifest.ItemCount, len(comics))
+	}
+
+	return comics, manifest, nil
+}
+
+// RestoreAPI はスナップショットの書き戻しに必要なDynamoDBクライアントのメソッドです。
+type RestoreAPI interface {
+	comicio.TableAPI
+	comicio.ScanAPI
+	comicio.BatchWriteAPI
+}
+
+// RestorePlan はスナップショットを書き戻すとテーブルに起きる変更です。
+type RestorePlan struct {
+	Manifest *Manifest
+	// TableName は書き戻すテーブルです。
+	TableName string
+	// Existing は書き戻す前のテーブルの件数です。テーブルがない場合は0です。
+	Existing int
+	// Deleted はスナップショットにないため削除する漫画のIDです。
+	Deleted []int
+
+	comics []entity.Comic
+}
+
+// PlanRestore はスナップショットを検証して読み込み、tableNameに書き戻した場合の変更を調べます。テーブルはまだ変更しません。
+func PlanRestore(ctx context.Context, api RestoreAPI, snapshotDir string, tableName string) (*RestorePlan, error) {
+	comics, manifest, err := Load(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+	plan := &RestorePlan{Manifest: manifest, TableName: tableName, comics: comics}
+
+	_, err = api.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: &tableName})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return plan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids, err := scanIDs(ctx, api, tableName)
+	if err != nil {
+		return nil, err
+	}
+	plan.Existing = len(ids)
+	plan.Deleted = missingIDs(ids, comics)
+	return plan, nil
+}
+
+// Restore はPlanRestoreで調べたスナップショットをテーブルに書き戻し、テーブルをスナップショットの時点と同じ内容にします。
+// テーブルがなければ作成します。スナップショットの漫画を書き込んでから、スナップショットにない漫画を削除するため、
+// 途中で失敗しても既存の漫画が消えたままにはなりません。削除する漫画は書き込み後のテーブルから改めて調べ、plan.Deletedに記録します。
+func Restore(ctx context.Context, api RestoreAPI, plan *RestorePlan) error {
+	if err := comicio.EnsureTable(ctx, api, plan.TableName); err != nil {
+		return err
+	}
+	if err := comicio.BatchWriteComics(ctx, api, plan.TableName, plan.comics); err != nil {
+		return err
+	}
+
+	ids, err := scanIDs(ctx, api, plan.TableName)
+	if err != nil {
+		return err
+	}
+	plan.Deleted = missingIDs(ids, plan.comics)
+	return comicio.BatchDeleteComics(ctx, api, plan.TableName, plan.Deleted)
+}
+
+// scanIDs はテーブルの全ての漫画のIDを返します。
+func scanIDs(ctx context.Context, api comicio.ScanAPI, tableName string) ([]int, error) {
+	items, err := comicio.ScanItems(ctx, api, tableName)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(items))
+	for _, item := range items {
+		var key struct{ ID int }
+		if err := attributevalue.UnmarshalMap(item, &key); err != nil {
+			return nil, err
+		}
+		ids = append(ids, key.ID)
+	}
+	return ids, nil
+}
+
+// missingIDs はidsのうちcomicsにないものを昇順で返します。
+func missingIDs(ids []int, comics []entity.Comic) []int {
+	keep := make(map[int]bool, len(comics))
+	for _, comic := range comics {
+		keep[comic.ID] = true
+	}
+	var missing []int
+	for _, id := range ids {
+		if !keep[id] {
+			missing = append(missing, id)
+		}
+	}
+	sort.Ints(missing)
+	return missing
+}
+
+func readManifest(snapshotDir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(snapshotDir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(snapshotDir, manifestFile), err)
+	}
+	return manifest, nil
+}
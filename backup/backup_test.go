@@ -0,0 +1,377 @@
+package backup
+
+import (
+	"comic-summaries/comicio"
+	"comic-summaries/entity"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDynamoDB はRestoreAPIとScanAPIを実装するメモリ上のDynamoDBです。Scanは1回で全件を返します。
+type fakeDynamoDB struct {
+	tables map[string]map[string]map[string]types.AttributeValue
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{tables: map[string]map[string]map[string]types.AttributeValue{}}
+}
+
+func (f *fakeDynamoDB) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if _, ok := f.tables[*params.TableName]; !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("not found")}
+	}
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableName: params.TableName, TableStatus: types.TableStatusActive}}, nil
+}
+
+func (f *fakeDynamoDB) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	f.tables[*params.TableName] = map[string]map[string]types.AttributeValue{}
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (f *fakeDynamoDB) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	for table, requests := range params.RequestItems {
+		for _, request := range requests {
+			if request.DeleteRequest != nil {
+				delete(f.tables[table], request.DeleteRequest.Key["ID"].(*types.AttributeValueMemberN).Value)
+				continue
+			}
+			id := request.PutRequest.Item["ID"].(*types.AttributeValueMemberN).Value
+			f.tables[table][id] = request.PutRequest.Item
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	out := &dynamodb.ScanOutput{}
+	for _, item := range f.tables[*params.TableName] {
+		out.Items = append(out.Items, item)
+	}
+	return out, nil
+}
+
+func (f *fakeDynamoDB) put(t *testing.T, table string, comics []entity.Comic) {
+	t.Helper()
+	if err := comicio.EnsureTable(context.Background(), f, table); err != nil {
+		t.Fatal(err)
+	}
+	if err := comicio.BatchWriteComics(context.Background(), f, table, comics); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// restore はsnapshotDirをtableNameに書き戻し、書き戻しの内容を返します。
+func restore(t *testing.T, api RestoreAPI, snapshotDir string, tableName string) *RestorePlan {
+	t.Helper()
+	plan, err := PlanRestore(context.Background(), api, snapshotDir, tableName)
+	if err != nil {
+		t.Fatalf("PlanRestore: %v", err)
+	}
+	if err := Restore(context.Background(), api, plan); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	return plan
+}
+
+func sampleComics() []entity.Comic {
+	return []entity.Comic{
+		{ID: 1, Title: "漫画1", Synopsis: "あらすじ\n2行目", Aliases: []string{"別名"}, Status: entity.StatusPublished,
+			CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{ID: 2, Title: "漫画2", BlurHash: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", DuplicateOf: 1},
+	}
+}
+
+func TestCreateLoadRestore(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDynamoDB()
+	db.put(t, "ComicSummaries", sampleComics())
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	snapshot, err := Create(ctx, db, "ComicSummaries", dir, now)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got, want := filepath.Base(snapshot.Dir), "ComicSummaries-20240601T120000.000Z"; got != want {
+		t.Errorf("snapshot directory = %s, want %s", got, want)
+	}
+	if snapshot.Manifest.ItemCount != 2 || snapshot.Manifest.SchemaVersion != SchemaVersion {
+		t.Errorf("manifest = %+v", snapshot.Manifest)
+	}
+
+	comics, _, err := Load(snapshot.Dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(comics, sampleComics()) {
+		t.Errorf("Load = %+v, want %+v", comics, sampleComics())
+	}
+
+	if plan := restore(t, db, snapshot.Dir, "Restored"); plan.Existing != 0 || len(plan.Deleted) != 0 {
+		t.Errorf("restoring into a new table: %+v", plan)
+	}
+	restored, err := comicio.ScanComics(ctx, db, "Restored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, sampleComics()) {
+		t.Errorf("restored table = %+v, want %+v", restored, sampleComics())
+	}
+}
+
+func TestRestoreReturnsTableToSnapshot(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDynamoDB()
+	db.put(t, "ComicSummaries", sampleComics())
+	snapshot, err := Create(ctx, db, "ComicSummaries", t.TempDir(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// スナップショットの後に漫画を編集・追加する
+	db.put(t, "ComicSummaries", []entity.Comic{{ID: 1, Title: "編集後"}, {ID: 3, Title: "漫画3"}, {ID: 4, Title: "漫画4"}})
+
+	plan, err := PlanRestore(ctx, db, snapshot.Dir, "ComicSummaries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Existing != 4 || !reflect.DeepEqual(plan.Deleted, []int{3, 4}) {
+		t.Errorf("plan = %+v, want 4 existing and 3, 4 deleted", plan)
+	}
+	// 調べただけではテーブルを変更しない
+	if current, _ := comicio.ScanComics(ctx, db, "ComicSummaries"); len(current) != 4 {
+		t.Fatalf("PlanRestore changed the table: %+v", current)
+	}
+
+	if err := Restore(ctx, db, plan); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := comicio.ScanComics(ctx, db, "ComicSummaries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, sampleComics()) {
+		t.Errorf("restored table = %+v, want the snapshot %+v", restored, sampleComics())
+	}
+}
+
+func TestCreateDoesNotOverwriteSnapshots(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDynamoDB()
+	db.put(t, "ComicSummaries", sampleComics())
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	first, err := Create(ctx, db, "ComicSummaries", dir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同じ秒でもミリ秒が違えば別のスナップショットになる
+	second, err := Create(ctx, db, "ComicSummaries", dir, now.Add(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Create in the same second: %v", err)
+	}
+	if first.Dir == second.Dir {
+		t.Fatalf("both snapshots were written to %s", first.Dir)
+	}
+	// 同じ時刻の場合は上書きせずにエラーにする
+	if _, err := Create(ctx, db, "ComicSummaries", dir, now); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create at the same time: err = %v, want an already exists error", err)
+	}
+	if _, _, err := Load(first.Dir); err != nil {
+		t.Errorf("the first snapshot is broken: %v", err)
+	}
+}
+
+func TestCreateRejectsItemsThatCannotBeRestored(t *testing.T) {
+	tests := []struct {
+		name  string
+		comic entity.Comic
+		want  string
+	}{
+		{"empty title", entity.Comic{ID: 3}, "title is empty"},
+		{"unknown status", entity.Comic{ID: 3, Title: "漫画3", Status: "archived"}, "unknown status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newFakeDynamoDB()
+			db.put(t, "ComicSummaries", append(sampleComics(), tt.comic))
+			dir := t.TempDir()
+
+			// 書き出せても復元できないスナップショットを作らない
+			if _, err := Create(ctx, db, "ComicSummaries", dir, time.Now()); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Create: err = %v, want an error containing %q", err, tt.want)
+			}
+			snapshots, err := List(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(snapshots) != 0 {
+				t.Errorf("Create left %d snapshots", len(snapshots))
+			}
+
+			// 直したテーブルのスナップショットは復元できる
+			db.put(t, "ComicSummaries", []entity.Comic{{ID: 3, Title: "漫画3"}})
+			snapshot, err := Create(ctx, db, "ComicSummaries", dir, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := Load(snapshot.Dir); err != nil {
+				t.Errorf("Load: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadDetectsCorruption(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDynamoDB()
+	db.put(t, "ComicSummaries", sampleComics())
+	snapshot, err := Create(ctx, db, "ComicSummaries", t.TempDir(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := filepath.Join(snapshot.Dir, dataFile)
+	if err := os.WriteFile(data, []byte("not gzip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load(snapshot.Dir); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Load with a modified data file: err = %v, want a checksum mismatch", err)
+	}
+}
+
+func TestPruneKeepsNewestPerTable(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDynamoDB()
+	db.put(t, "A", sampleComics())
+	db.put(t, "B", sampleComics())
+	dir := t.TempDir()
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if _, err := Create(ctx, db, "A", dir, base.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Create(ctx, db, "B", dir, base); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Prune(dir, 2, 0, base.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Prune removed %d snapshots, want 2", len(removed))
+	}
+	// 期限切れでも各テーブルの最新の1件は残す
+	if _, err := Prune(dir, 2, time.Hour, base.Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	remaining, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, snapshot := range remaining {
+		names = append(names, filepath.Base(snapshot.Dir))
+	}
+	sort.Strings(names)
+	want := []string{"A-20240601T030000.000Z", "B-20240601T000000.000Z"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("remaining snapshots = %v, want %v", names, want)
+	}
+
+	latest, err := Latest(dir, "A")
+	if err != nil || filepath.Base(latest.Dir) != "A-20240601T030000.000Z" {
+		t.Errorf("Latest = %v, %v", latest, err)
+	}
+}
+
+// TestDynamoDBLocal はDynamoDB Localに対してバックアップと復元を通して確かめます。
+// DYNAMODB_LOCAL_ENDPOINT (例: http://localhost:8000) を設定した場合だけ実行します。
+func TestDynamoDBLocal(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT is not set; start dynamodb-local with docker compose to run this test")
+	}
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion("us-east-1"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("local", "local", "")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	})
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	source, restored := "BackupTest-"+suffix, "RestoreTest-"+suffix
+	for _, table := range []string{source, restored} {
+		table := table
+		t.Cleanup(func() {
+			client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(table)})
+		})
+	}
+
+	var comics []entity.Comic
+	// BatchWriteItemの1回の上限 (25件) とScanのページングをまたぐ件数にする
+	for i := 1; i <= 60; i++ {
+		comics = append(comics, entity.Comic{ID: i, Title: fmt.Sprintf("漫画%d", i), Synopsis: strings.Repeat("あ", 1000)})
+	}
+	if err := comicio.EnsureTable(ctx, client, source); err != nil {
+		t.Fatal(err)
+	}
+	if err := comicio.BatchWriteComics(ctx, client, source, comics); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := Create(ctx, client, source, t.TempDir(), time.Now())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if snapshot.Manifest.ItemCount != len(comics) {
+		t.Fatalf("snapshot has %d items, want %d", snapshot.Manifest.ItemCount, len(comics))
+	}
+	// 書き戻し先にだけある漫画は削除され、スナップショットの時点の内容になる
+	extra := []entity.Comic{{ID: 1, Title: "編集後"}, {ID: 100, Title: "後から追加"}}
+	if err := comicio.EnsureTable(ctx, client, restored); err != nil {
+		t.Fatal(err)
+	}
+	if err := comicio.BatchWriteComics(ctx, client, restored, extra); err != nil {
+		t.Fatal(err)
+	}
+	if plan := restore(t, client, snapshot.Dir, restored); !reflect.DeepEqual(plan.Deleted, []int{100}) {
+		t.Errorf("deleted = %v, want [100]", plan.Deleted)
+	}
+
+	items, err := comicio.ScanItems(ctx, client, restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []entity.Comic
+	if err := attributevalue.UnmarshalListOfMaps(items, &got); err != nil {
+		t.Fatal(err)
+	}
+	comicio.SortByID(got)
+	if !reflect.DeepEqual(got, comics) {
+		t.Errorf("restored table differs from the source: got %d comics, want %d", len(got), len(comics))
+	}
+}
@@ -0,0 +1,93 @@
+package canon
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultPatterns はタイトルから取り除く版・キャンペーン表記の既定の規則です。
+// 実際の表記に合わせて -title-rules で指定したファイルで置き換えてください。
+var DefaultPatterns = []string{
+	// 周年記念・値下げキャンペーンの接頭辞 (例: "21st Anniversary down アイシールド21")
+	`(?i)^\d+(st|nd|rd|th)\s+anniversary(\s+down)?\s+`,
+	// 【期間限定無料】のような隅付き括弧のキャンペーン表記
+	`【[^】]*(無料|限定|試し読み|特典|配信)[^】]*】`,
+	// (分冊版) のような括弧付きの版表記
+	`[(（](分冊版|単話版|話売り|フルカラー|カラー版|モノクロ版|新装版|完全版|合本版|愛蔵版)[)）]`,
+	// 括弧なしの版表記
+	`(^|\s)(分冊版|単話版|フルカラー版|カラー版|モノクロ版|新装版|完全版|合本版|愛蔵版)(\s|$)`,
+}
+
+// Rules はタイトルの正規化の規則です。
+type Rules struct {
+	strip []*regexp.Regexp
+}
+
+// NewRules は取り除く表記の正規表現から規則を生成します。
+func NewRules(patterns []string) (*Rules, error) {
+	r := &Rules{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", pattern, err)
+		}
+		r.strip = append(r.strip, re)
+	}
+	return r, nil
+}
+
+// DefaultRules はDefaultPatternsの規則を返します。
+func DefaultRules() *Rules {
+	r, err := NewRules(DefaultPatterns)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// LoadRules は正規表現の配列を書いたJSONファイルから規則を読み込みます。
+func LoadRules(filename string) (*Rules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	if err := json.Unmarshal(data, &patterns); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return NewRules(patterns)
+}
+
+// Canonical は版・キャンペーン表記を取り除いた表示用のタイトルを返します。
+// 作品名の表記("！" や "～" など)はそのまま残し、取り除いた箇所の空白だけを1つにまとめます。
+func (r *Rules) Canonical(title string) string {
+	stripped := false
+	for _, re := range r.strip {
+		if re.MatchString(title) {
+			title = re.ReplaceAllString(title, " ")
+			stripped = true
+		}
+	}
+	if stripped {
+		return strings.Join(strings.Fields(title), " ")
+	}
+	return strings.TrimSpace(title)
+}
+
+// Key は表記揺れを吸収した比較用のキーを返します。
+// NFKCで正規化して小文字にし、文字と数字以外(空白・記号・句読点)を取り除きます。
+func Key(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(norm.NFKC.String(title)) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package canon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"21st Anniversary down アイシールド21", "アイシールド21"},
+		{"21st Anniversary アイシールド21 BRAIN×BRAVE", "アイシールド21 BRAIN×BRAVE"},
+		{"3rd anniversary  ワンピース", "ワンピース"},
+		{"【期間限定無料】ワンピース", "ワンピース"},
+		{"【7日間限定 試し読み増量】ワンピース 1", "ワンピース 1"},
+		{"進撃の巨人（分冊版）", "進撃の巨人"},
+		{"進撃の巨人(単話版) 【無料配信】", "進撃の巨人"},
+		{"キャプテン翼 新装版 1", "キャプテン翼 1"},
+		{"進撃の巨人 フルカラー版", "進撃の巨人"},
+		// 作品名の一部の表記や、関係のない括弧は残す
+		{"ドラゴンボール完全版", "ドラゴンボール完全版"},
+		{"【推しの子】", "【推しの子】"},
+		{"ONE PIECE！", "ONE PIECE！"},
+		{"Anniversary", "Anniversary"},
+		{"  ワンピース  ", "ワンピース"},
+	}
+	rules := DefaultRules()
+	for _, tt := range tests {
+		if got := rules.Canonical(tt.title); got != tt.want {
+			t.Errorf("Canonical(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"ONE PIECE", "onepiece"},
+		{"ＯＮＥ　ＰＩＥＣＥ！", "onepiece"},
+		{"アイシールド21 BRAIN×BRAVE", "アイシールド21brainbrave"},
+		{"ｱｲｼｰﾙﾄﾞ21", "アイシールド21"},
+		{"Dr.STONE", "drstone"},
+		{"かぐや様は告らせたい～天才たちの恋愛頭脳戦～", "かぐや様は告らせたい天才たちの恋愛頭脳戦"},
+		{"②", "2"},
+		{"！？", ""},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.title); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(valid, []byte(`["\\s*第\\d+話$"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules.Canonical("ワンピース 第12話"); got != "ワンピース" {
+		t.Errorf("Canonical with loaded rules = %q", got)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`["("]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRules(invalid); err == nil {
+		t.Error("LoadRules with an invalid regular expression succeeded")
+	}
+}
@@ -0,0 +1,107 @@
+package canon
+
+import (
+	"comic-summaries/entity"
+	"strings"
+	"unicode/utf8"
+)
+
+// 一致の種類を表すMatch.Kindの値です。
+const (
+	// MatchExact は正規化したタイトルまたは別名が既存の漫画と同じです。同じ作品として扱います。
+	MatchExact = "exact"
+	// MatchLikely は一方のキーがもう一方の先頭に一致します。"キャプテン翼" と "キャプテン翼 ワールドユース編" のように
+	// 続編や番外編の可能性もあるため、自動では統合せずレビューで判断します。
+	MatchLikely = "likely"
+)
+
+// minLikelyKeyLength は前方一致で重複候補とみなすキーの最小文字数です。短いタイトルが無関係の作品に一致しないようにします。
+const minLikelyKeyLength = 4
+
+// Match は既存の漫画との一致です。
+type Match struct {
+	Comic *entity.Comic
+	Kind  string
+	// Name は一致した既存の漫画のタイトルまたは別名です。
+	Name string
+}
+
+type indexEntry struct {
+	key   string
+	name  string
+	comic *entity.Comic
+}
+
+// Index は既存の漫画のタイトルと別名を正規化したキーで引けるようにします。
+type Index struct {
+	rules   *Rules
+	entries []indexEntry
+	exact   map[string]int
+}
+
+// NewIndex はcomicsのタイトルと別名からIndexを生成します。
+func NewIndex(rules *Rules, comics []*entity.Comic) *Index {
+	ix := &Index{
+		rules: rules,
+		exact: map[string]int{},
+	}
+	for _, comic := range comics {
+		ix.Add(comic)
+	}
+	return ix
+}
+
+// Add は漫画のタイトルと別名をIndexに追加します。
+func (ix *Index) Add(comic *entity.Comic) {
+	for _, name := range append([]string{comic.Title}, comic.Aliases...) {
+		key := Key(ix.rules.Canonical(name))
+		if key == "" {
+			continue
+		}
+		if _, ok := ix.exact[key]; ok {
+			continue
+		}
+		ix.exact[key] = len(ix.entries)
+		ix.entries = append(ix.entries, indexEntry{key: key, name: name, comic: comic})
+	}
+}
+
+// Match はtitleに一致する既存の漫画を返します。完全一致を優先し、なければ前方一致する中でキーの長さが最も近い漫画を返します。
+// 一致しない場合はnilを返します。
+func (ix *Index) Match(title string) *Match {
+	key := Key(ix.rules.Canonical(title))
+	if key == "" {
+		return nil
+	}
+	if i, ok := ix.exact[key]; ok {
+		e := ix.entries[i]
+		return &Match{Comic: e.comic, Kind: MatchExact, Name: e.name}
+	}
+
+	var best *indexEntry
+	for i := range ix.entries {
+		e := &ix.entries[i]
+		shorter, longer := e.key, key
+		if len(shorter) > len(longer) {
+			shorter, longer = longer, shorter
+		}
+		if utf8.RuneCountInString(shorter) < minLikelyKeyLength || !strings.HasPrefix(longer, shorter) {
+			continue
+		}
+		if best == nil || lengthDiff(e.key, key) < lengthDiff(best.key, key) {
+			best = e
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return &Match{Comic: best.comic, Kind: MatchLikely, Name: best.name}
+}
+
+// lengthDiff はキーの長さの差です。前方一致する中で差が最も小さいものが、最も近い作品です。
+func lengthDiff(a, b string) int {
+	if len(a) > len(b) {
+		return len(a) - len(b)
+	}
+	return len(b) - len(a)
+}
@@ -0,0 +1,82 @@
+package canon
+
+import (
+	"comic-summaries/entity"
+	"testing"
+)
+
+func TestIndexMatch(t *testing.T) {
+	comics := []*entity.Comic{
+		{ID: 1, Title: "アイシールド21", Aliases: []string{"Eyeshield 21"}},
+		{ID: 2, Title: "キャプテン翼"},
+		{ID: 3, Title: "キャプテン翼 ワールドユース編"},
+		{ID: 4, Title: "ワンピース"},
+		{ID: 5, Title: "NANA"},
+		{ID: 6, Title: "ぼく"},
+	}
+	ix := NewIndex(DefaultRules(), comics)
+
+	tests := []struct {
+		title    string
+		wantID   int // 0の場合は一致しない
+		wantKind string
+		wantName string
+	}{
+		{"アイシールド21", 1, MatchExact, "アイシールド21"},
+		{"21st Anniversary down アイシールド21", 1, MatchExact, "アイシールド21"},
+		{"ＥＹＥＳＨＩＥＬＤ　２１", 1, MatchExact, "Eyeshield 21"},
+		// 版の表記を取り除いても残る副題は続編の可能性があるため重複候補にする
+		{"21st Anniversary アイシールド21 BRAIN×BRAVE", 1, MatchLikely, "アイシールド21"},
+		{"キャプテン翼 ライジングサン", 2, MatchLikely, "キャプテン翼"},
+		// 前方一致する中で長さの最も近いタイトルを選ぶ
+		{"キャプテン翼 ワールドユース編 完全版", 3, MatchExact, "キャプテン翼 ワールドユース編"},
+		{"キャプテン翼 ワールドユース編 特別編", 3, MatchLikely, "キャプテン翼 ワールドユース編"},
+		// 入力の方が短い場合も前方一致を見て、長さの最も近いタイトルを選ぶ
+		{"キャプテン", 2, MatchLikely, "キャプテン翼"},
+		// キーがちょうどminLikelyKeyLength文字なら前方一致を見る
+		{"NANA 2", 5, MatchLikely, "NANA"},
+		{"ワンピ", 0, "", ""},
+		{"ワンピー", 4, MatchLikely, "ワンピース"},
+		// 短い既存のタイトルが無関係の作品に一致しない
+		{"ぼくらの", 0, "", ""},
+		{"ぼく", 6, MatchExact, "ぼく"},
+		// 途中に含むだけでは一致しない
+		{"新ワンピース", 0, "", ""},
+		{"【期間限定無料】", 0, "", ""},
+		{"", 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			m := ix.Match(tt.title)
+			if tt.wantID == 0 {
+				if m != nil {
+					t.Errorf("Match(%q) = %d %s (%s), want no match", tt.title, m.Comic.ID, m.Name, m.Kind)
+				}
+				return
+			}
+			if m == nil {
+				t.Fatalf("Match(%q) = nil, want %d", tt.title, tt.wantID)
+			}
+			if m.Comic.ID != tt.wantID || m.Kind != tt.wantKind || m.Name != tt.wantName {
+				t.Errorf("Match(%q) = %d %q (%s), want %d %q (%s)", tt.title, m.Comic.ID, m.Name, m.Kind, tt.wantID, tt.wantName, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestIndexAddMatchesPendingComics(t *testing.T) {
+	ix := NewIndex(DefaultRules(), nil)
+	pending := &entity.Comic{ID: 10, Title: "ダンジョン飯"}
+	ix.Add(pending)
+
+	m := ix.Match("ダンジョン飯 ワールドガイド")
+	if m == nil || m.Comic != pending || m.Kind != MatchLikely {
+		t.Fatalf("Match = %+v, want the pending comic", m)
+	}
+	// 後から追加した別名でも引ける
+	pending.AddAlias("Delicious in Dungeon")
+	ix.Add(pending)
+	if m := ix.Match("DELICIOUS IN DUNGEON"); m == nil || m.Comic != pending || m.Kind != MatchExact {
+		t.Errorf("Match by alias = %+v", m)
+	}
+}
@@ -0,0 +1,312 @@
+package comicio
+
+import (
+	"comic-summaries/entity"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumn はCSVの1列と漫画データのフィールドの対応です。
+type csvColumn struct {
+	name string
+	get  func(c *entity.Comic) string
+	set  func(c *entity.Comic, value string) error
+}
+
+func stringColumn(name string, field func(c *entity.Comic) *string) csvColumn {
+	return csvColumn{
+		name: name,
+		get:  func(c *entity.Comic) string { return *field(c) },
+		set: func(c *entity.Comic, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func timeColumn(name string, field func(c *entity.Comic) *time.Time) csvColumn {
+	return csvColumn{
+		name: name,
+		get: func(c *entity.Comic) string {
+			if field(c).IsZero() {
+				return ""
+			}
+			return field(c).Format(time.RFC3339Nano)
+		},
+		set: func(c *entity.Comic, value string) error {
+			if value == "" {
+				*field(c) = time.Time{}
+				return nil
+			}
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, value, err)
+			}
+			*field(c) = t
+			return nil
+		},
+	}
+}
+
+// csvColumns はCSVの列です。エクスポートとインポートで同じ列順を使用します。
+// インポートはヘッダーの列名で対応付けるため、後から追加した列がない古いCSVも読み込めます。
+var csvColumns = []csvColumn{
+	{
+		name: "ID",
+		get:  func(c *entity.Comic) string { return strconv.Itoa(c.ID) },
+		set: func(c *entity.Comic, value string) error {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid ID %q: %w", value, err)
+			}
+			c.ID = id
+			return nil
+		},
+	},
+	stringColumn("Title", func(c *entity.Comic) *string { return &c.Title }),
+	stringColumn("Synopsis", func(c *entity.Comic) *string { return &c.Synopsis }),
+	stringColumn("Attraction", func(c *entity.Comic) *string { return &c.Attraction }),
+	stringColumn("Spoilers", func(c *entity.Comic) *string { return &c.Spoilers }),
+	stringColumn("Genre", func(c *entity.Comic) *string { return &c.Genre }),
+	stringColumn("Characters", func(c *entity.Comic) *string { return &c.Characters }),
+	stringColumn("ImagePath", func(c *entity.Comic) *string { return &c.ImagePath }),
+	{
+		// サイズ・形式違いの画像は入れ子の構造のためJSONで1列に収める
+		name: "Images",
+		get: func(c *entity.Comic) string {
+			if c.Images == nil {
+				return ""
+			}
+			data, _ := json.Marshal(c.Images)
+			return string(data)
+		},
+		set: func(c *entity.Comic, value string) error {
+			c.Images = nil
+			if value == "" {
+				return nil
+			}
+			images := new(entity.ImageSet)
+			if err := json.Unmarshal([]byte(value), images); err != nil {
+				return fmt.Errorf("invalid Images: %w", err)
+			}
+			c.Images = images
+			return nil
+		},
+	},
+	stringColumn("BlurHash", func(c *entity.Comic) *string { return &c.BlurHash }),
+	stringColumn("DominantColor", func(c *entity.Comic) *string { return &c.DominantColor }),
+	{
+		name: "ImageBroken",
+		get: func(c *entity.Comic) string {
+			if !c.ImageBroken {
+				return ""
+			}
+			return "true"
+		},
+		set: func(c *entity.Comic, value string) error {
+			if value == "" {
+				c.ImageBroken = false
+				return nil
+			}
+			broken, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid ImageBroken %q: %w", value, err)
+			}
+			c.ImageBroken = broken
+			return nil
+		},
+	},
+	stringColumn("Model", func(c *entity.Comic) *string { return &c.Model }),
+	stringColumn("PromptVersion", func(c *entity.Comic) *string { return &c.PromptVersion }),
+	timeColumn("CreatedAt", func(c *entity.Comic) *time.Time { return &c.CreatedAt }),
+	timeColumn("UpdatedAt", func(c *entity.Comic) *time.Time { return &c.UpdatedAt }),
+	stringColumn("Source", func(c *entity.Comic) *string { return &c.Source }),
+	stringColumn("SourceURL", func(c *entity.Comic) *string { return &c.SourceURL }),
+	stringColumn("Status", func(c *entity.Comic) *string { return &c.Status }),
+	stringColumn("ReviewComment", func(c *entity.Comic) *string { return &c.ReviewComment }),
+	stringColumn("ReviewedBy", func(c *entity.Comic) *string { return &c.ReviewedBy }),
+	{
+		// 別名はタイトルに含まれない改行で区切る
+		name: "Aliases",
+		get:  func(c *entity.Comic) string { return strings.Join(c.Aliases, "\n") },
+		set: func(c *entity.Comic, value string) error {
+			c.Aliases = nil
+			if value != "" {
+				c.Aliases = strings.Split(value, "\n")
+			}
+			return nil
+		},
+	},
+	{
+		name: "DuplicateOf",
+		get: func(c *entity.Comic) string {
+			if c.DuplicateOf == 0 {
+				return ""
+			}
+			return strconv.Itoa(c.DuplicateOf)
+		},
+		set: func(c *entity.Comic, value string) error {
+			if value == "" {
+				c.DuplicateOf = 0
+				return nil
+			}
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid DuplicateOf %q: %w", value, err)
+			}
+			c.DuplicateOf = id
+			return nil
+		},
+	},
+	{
+		// レビュー待ちの要約も入れ子の構造のためJSONで1列に収める
+		name: "Pending",
+		get: func(c *entity.Comic) string {
+			if c.Pending == nil {
+				return ""
+			}
+			data, _ := json.Marshal(c.Pending)
+			return string(data)
+		},
+		set: func(c *entity.Comic, value string) error {
+			c.Pending = nil
+			if value == "" {
+				return nil
+			}
+			pending := new(entity.PendingSummary)
+			if err := json.Unmarshal([]byte(value), pending); err != nil {
+				return fmt.Errorf("invalid Pending: %w", err)
+			}
+			c.Pending = pending
+			return nil
+		},
+	},
+}
+
+// WriteCSV は漫画データをヘッダー付きのCSVとして書き出します。IDは採番し直さずにそのまま出力します。
+// 値の中の改行はそのまま残りますが、\r\nは読み込み時に\nになります。
+func WriteCSV(w io.Writer, comics []entity.Comic) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		header[i] = column.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for i := range comics {
+		row := make([]string, len(csvColumns))
+		for j, column := range csvColumns {
+			row[j] = column.get(&comics[i])
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadCSV はWriteCSVで書き出したCSVを読み込みます。IDが数値でない行はエラーとします。
+func ReadCSV(r io.Reader) ([]entity.Comic, error) {
+	comics, _, err := ReadCSVColumns(r)
+	return comics, err
+}
+
+// ReadCSVColumns はReadCSVと同じようにCSVを読み込み、ヘッダーにあった列の名前も返します。
+// 知らない列は読み飛ばし、返す列にも含めません。
+func ReadCSVColumns(r io.Reader) ([]entity.Comic, []string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+
+	// ヘッダーの列名から列の位置を求める
+	columns := make([]*csvColumn, len(records[0]))
+	found := make(map[string]bool)
+	var names []string
+	for i, name := range records[0] {
+		if column := findColumn(name); column != nil {
+			columns[i] = column
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, required := range []string{"ID", "Title"} {
+		if !found[required] {
+			return nil, nil, fmt.Errorf("header has no %s column", required)
+		}
+	}
+
+	var comics []entity.Comic
+	for i, record := range records[1:] {
+		if len(record) != len(columns) {
+			return nil, nil, fmt.Errorf("row %d: expected %d columns, got %d", i+2, len(columns), len(record))
+		}
+
+		var comic entity.Comic
+		for j, value := range record {
+			if columns[j] == nil {
+				continue
+			}
+			if err := columns[j].set(&comic, value); err != nil {
+				return nil, nil, fmt.Errorf("row %d: %w", i+2, err)
+			}
+		}
+		comics = append(comics, comic)
+	}
+
+	return comics, names, nil
+}
+
+// findColumn は名前の列を返します。知らない列の場合はnilを返します。
+func findColumn(name string) *csvColumn {
+	for i := range csvColumns {
+		if csvColumns[i].name == name {
+			return &csvColumns[i]
+		}
+	}
+	return nil
+}
+
+// Merge はcurrentにrecordのcolumnsの列だけを重ねた漫画を返します。columnsにない列は保存済みの値を残すため、
+// 後から追加した列がない古いCSVを読み込み直しても画像やレビューの状態を消しません。columnsがnilの場合はrecordを返します。
+func Merge(current *entity.Comic, record *entity.Comic, columns []string) (entity.Comic, error) {
+	if columns == nil {
+		return *record, nil
+	}
+	fromRecord := make(map[string]bool, len(columns))
+	for _, name := range columns {
+		if findColumn(name) == nil {
+			return entity.Comic{}, fmt.Errorf("unknown column %q", name)
+		}
+		fromRecord[name] = true
+	}
+
+	// 全ての列を値の変換を通して写すことで、スライスやポインタをcurrentやrecordと共有しない
+	var merged entity.Comic
+	for _, column := range csvColumns {
+		src := current
+		if fromRecord[column.name] {
+			src = record
+		}
+		if err := column.set(&merged, column.get(src)); err != nil {
+			return entity.Comic{}, err
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,222 @@
+package comicio
+
+import (
+	"bytes"
+	"comic-summaries/entity"
+	"context"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeTable はScanAPIとBatchWriteAPIを実装するメモリ上のテーブルです。
+// スキャンの順序に依存しないことを確かめるため、IDの降順にpageSize件ずつ返します。
+type fakeTable struct {
+	items    map[string]map[string]types.AttributeValue
+	pageSize int
+}
+
+func newFakeTable(pageSize int) *fakeTable {
+	return &fakeTable{items: map[string]map[string]types.AttributeValue{}, pageSize: pageSize}
+}
+
+func (t *fakeTable) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	for _, requests := range params.RequestItems {
+		for _, request := range requests {
+			id := request.PutRequest.Item["ID"].(*types.AttributeValueMemberN).Value
+			t.items[id] = request.PutRequest.Item
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (t *fakeTable) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	ids := make([]int, 0, len(t.items))
+	for key := range t.items {
+		id, _ := strconv.Atoi(key)
+		ids = append(ids, id)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+
+	start := 0
+	if params.ExclusiveStartKey != nil {
+		last, _ := strconv.Atoi(params.ExclusiveStartKey["ID"].(*types.AttributeValueMemberN).Value)
+		for start < len(ids) && ids[start] >= last {
+			start++
+		}
+	}
+	end := start + t.pageSize
+	if end > len(ids) {
+		end = len(ids)
+	}
+
+	out := &dynamodb.ScanOutput{}
+	for _, id := range ids[start:end] {
+		out.Items = append(out.Items, t.items[strconv.Itoa(id)])
+	}
+	if end < len(ids) {
+		out.LastEvaluatedKey = map[string]types.AttributeValue{"ID": &types.AttributeValueMemberN{Value: strconv.Itoa(ids[end-1])}}
+	}
+	return out, nil
+}
+
+func testComics() []entity.Comic {
+	created := time.Date(2024, 5, 1, 9, 30, 0, 123456789, time.UTC)
+	return []entity.Comic{
+		{
+			ID:       1,
+			Title:    "ONE PIECE",
+			Synopsis: "1行目\n2行目, カンマ入り\n\"引用\"",
+			// encoding/csvは引用符内の\r\nを\nとして読むため、改行は\nだけを使う
+			Attraction: "魅力\n\n改行",
+			Spoilers:   "ネタバレ",
+			Genre:      "少年漫画",
+			Characters: "ルフィ\nゾロ",
+			ImagePath:  "https://example.com/images/abc.jpg",
+			Images: &entity.ImageSet{
+				Src:    "https://example.com/images/abc-480.jpg",
+				Width:  800,
+				Height: 1200,
+				Sources: []entity.ImageSource{{
+					Type:     "image/webp",
+					SrcSet:   "https://example.com/images/abc-240.webp 240w",
+					Variants: []entity.ImageVariant{{Width: 240, Height: 360, URL: "https://example.com/images/abc-240.webp"}},
+				}},
+			},
+			BlurHash:      "LEHV6nWB2yk8pyo0adR*.7kCMdnj",
+			DominantColor: "#a1b2c3",
+			ImageBroken:   true,
+			Model:         "gpt-4o",
+			PromptVersion: "v2",
+			CreatedAt:     created,
+			UpdatedAt:     created.Add(time.Hour),
+			Source:        entity.SourceLLM,
+			SourceURL:     "https://example.com/title/1",
+			Status:        entity.StatusInReview,
+			ReviewComment: "確認してください\n2行目",
+			ReviewedBy:    "editor",
+			Aliases:       []string{"ワンピース", "One Piece, Vol. 1"},
+			DuplicateOf:   3,
+		},
+		{ID: 2, Title: "最小限の漫画"},
+		{ID: 3, Title: "3件目", Synopsis: "", Status: entity.StatusPublished, Pending: &entity.PendingSummary{Synopsis: "再生成したあらすじ\n2行目", Genre: "青年漫画", PromptVersion: "v3"}},
+	}
+}
+
+func TestCSVRoundTripThroughTable(t *testing.T) {
+	want := testComics()
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, want); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	read, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	if !reflect.DeepEqual(read, want) {
+		t.Fatalf("ReadCSV(WriteCSV(comics)) =\n%+v\nwant\n%+v", read, want)
+	}
+
+	ctx := context.Background()
+	table := newFakeTable(2)
+	if err := BatchWriteComics(ctx, table, "ComicSummaries", read); err != nil {
+		t.Fatalf("BatchWriteComics: %v", err)
+	}
+	scanned, err := ScanComics(ctx, table, "ComicSummaries")
+	if err != nil {
+		t.Fatalf("ScanComics: %v", err)
+	}
+	if len(scanned) != len(want) {
+		t.Fatalf("ScanComics returned %d comics, want %d", len(scanned), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(scanned[i], want[i]) {
+			t.Errorf("comic %d after the table round trip =\n%+v\nwant\n%+v", want[i].ID, scanned[i], want[i])
+		}
+	}
+}
+
+func TestReadCSVOldColumns(t *testing.T) {
+	// 後から追加した列がない古いCSVも読み込める
+	old := "ID,Title,Synopsis,Attraction,Spoilers,Genre,Characters,ImagePath\n" +
+		"7,古い漫画,\"あらすじ\n2行目\",魅力,ネタバレ,ジャンル,登場人物,https://example.com/a.jpg\n"
+	comics, err := ReadCSV(bytes.NewBufferString(old))
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	want := []entity.Comic{{
+		ID: 7, Title: "古い漫画", Synopsis: "あらすじ\n2行目", Attraction: "魅力", Spoilers: "ネタバレ",
+		Genre: "ジャンル", Characters: "登場人物", ImagePath: "https://example.com/a.jpg",
+	}}
+	if !reflect.DeepEqual(comics, want) {
+		t.Errorf("ReadCSV = %+v, want %+v", comics, want)
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing ID column": "Title\nx\n",
+		"non-numeric ID":    "ID,Title\nabc,x\n",
+		"bad time":          "ID,Title,CreatedAt\n1,x,yesterday\n",
+		"bad Images JSON":   "ID,Title,Images\n1,x,{\n",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ReadCSV(bytes.NewBufferString(input)); err == nil {
+				t.Errorf("ReadCSV(%q) succeeded, want an error", input)
+			}
+		})
+	}
+}
+
+// 属性値の変換でもフィールドが落ちないことを確かめる
+func TestMarshalKeepsEveryField(t *testing.T) {
+	want := testComics()[0]
+	item, err := attributevalue.MarshalMap(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got entity.Comic
+	if err := attributevalue.UnmarshalMap(item, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergeKeepsColumnsMissingFromTheFile(t *testing.T) {
+	current := testComics()[0]
+	old := "ID,Title,Synopsis,Attraction,Spoilers,Genre,Characters,ImagePath\n" +
+		"1,ONE PIECE,新しいあらすじ,魅力,ネタバレ,少年漫画,ルフィ,https://example.com/images/abc.jpg\n"
+	records, columns, err := ReadCSVColumns(bytes.NewBufferString(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := Merge(&current, &records[0], columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testComics()[0]
+	want.Synopsis, want.Attraction, want.Characters = "新しいあらすじ", "魅力", "ルフィ"
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merge =\n%+v\nwant\n%+v", merged, want)
+	}
+	// 複製したスライスを書き換えても保存済みの漫画は変わらない
+	merged.Aliases[0] = "changed"
+	if current.Aliases[0] != "ワンピース" {
+		t.Error("Merge shares Aliases with the current comic")
+	}
+
+	// 列の指定がなければファイルの内容で置き換える
+	if replaced, err := Merge(&current, &records[0], nil); err != nil || !reflect.DeepEqual(replaced, records[0]) {
+		t.Errorf("Merge without columns = %+v, %v", replaced, err)
+	}
+}
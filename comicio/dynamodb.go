@@ -0,0 +1,177 @@
+package comicio
+
+import (
+	"comic-summaries/entity"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ScanAPI はテーブル全件の読み込みに必要なDynamoDBクライアントのメソッドです。
+// インメモリのテーブルなど、任意の実装に差し替えられます。
+type ScanAPI interface {
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// ScanItems は指定したテーブルを最後までスキャンして全アイテムを返します。
+func ScanItems(ctx context.Context, api ScanAPI, tableName string) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	for {
+		out, err := api.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         &tableName,
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, out.Items...)
+
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		lastEvaluatedKey = out.LastEvaluatedKey
+	}
+
+	return items, nil
+}
+
+// ScanComics はテーブルの全件を漫画データとして読み込み、ID順に並べて返します。
+func ScanComics(ctx context.Context, api ScanAPI, tableName string) ([]entity.Comic, error) {
+	items, err := ScanItems(ctx, api, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var comics []entity.Comic
+	if err := attributevalue.UnmarshalListOfMaps(items, &comics); err != nil {
+		return nil, err
+	}
+	SortByID(comics)
+
+	return comics, nil
+}
+
+// SortByID は漫画データをID昇順に並べ替えます。スキャン順に依存しない出力にするために使用します。
+func SortByID(comics []entity.Comic) {
+	sort.SliceStable(comics, func(i, j int) bool {
+		return comics[i].ID < comics[j].ID
+	})
+}
+
+// BatchWriteAPI はテーブルへの一括書き込みに必要なDynamoDBクライアントのメソッドです。
+type BatchWriteAPI interface {
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// batchWriteSize はBatchWriteItemで一度に書き込める最大件数です。
+const batchWriteSize = 25
+
+// maxUnprocessedRetries は未処理アイテムを再送する最大回数です。
+const maxUnprocessedRetries = 5
+
+// BatchWriteComics は漫画データを25件ずつテーブルに書き込みます。未処理のアイテムは間隔を空けて再送します。
+func BatchWriteComics(ctx context.Context, api BatchWriteAPI, tableName string, comics []entity.Comic) error {
+	writeRequests := make([]types.WriteRequest, 0, len(comics))
+	for _, comic := range comics {
+		item, err := attributevalue.MarshalMap(comic)
+		if err != nil {
+			return err
+		}
+		writeRequests = append(writeRequests, types.WriteRequest{
+			PutRequest: &types.PutRequest{Item: item},
+		})
+	}
+	return batchWrite(ctx, api, tableName, writeRequests)
+}
+
+// BatchDeleteComics は指定したIDの漫画を25件ずつテーブルから削除します。未処理のアイテムは間隔を空けて再送します。
+func BatchDeleteComics(ctx context.Context, api BatchWriteAPI, tableName string, ids []int) error {
+	writeRequests := make([]types.WriteRequest, 0, len(ids))
+	for _, id := range ids {
+		writeRequests = append(writeRequests, types.WriteRequest{
+			DeleteRequest: &types.DeleteRequest{Key: map[string]types.AttributeValue{
+				"ID": &types.AttributeValueMemberN{Value: strconv.Itoa(id)},
+			}},
+		})
+	}
+	return batchWrite(ctx, api, tableName, writeRequests)
+}
+
+// batchWrite は書き込みのリクエストを25件ずつ送ります。
+func batchWrite(ctx context.Context, api BatchWriteAPI, tableName string, writeRequests []types.WriteRequest) error {
+	for i := 0; i < len(writeRequests); i += batchWriteSize {
+		end := i + batchWriteSize
+		if end > len(writeRequests) {
+			end = len(writeRequests)
+		}
+
+		requestItems := map[string][]types.WriteRequest{tableName: writeRequests[i:end]}
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt > maxUnprocessedRetries {
+				return fmt.Errorf("batch starting at %d: unprocessed items remain after %d retries", i, maxUnprocessedRetries)
+			}
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Duration(attempt) * time.Second):
+				}
+			}
+
+			out, err := api.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return err
+			}
+			requestItems = out.UnprocessedItems
+		}
+	}
+
+	return nil
+}
+
+// TableAPI はテーブルの存在確認と作成に必要なDynamoDBクライアントのメソッドです。
+type TableAPI interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+}
+
+// EnsureTable はComicSummariesと同じキー構成のテーブルがなければ作成し、利用可能になるまで待ちます。
+func EnsureTable(ctx context.Context, api TableAPI, tableName string) error {
+	_, err := api.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: &tableName})
+	if err == nil {
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return err
+	}
+
+	_, err = api.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: &tableName,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: types.KeyTypeHash},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		return err
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(api)
+	return waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: &tableName}, 2*time.Minute)
+}
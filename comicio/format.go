@@ -0,0 +1,137 @@
+package comicio
+
+import (
+	"comic-summaries/entity"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format はエクスポート・インポートで扱うファイル形式です。
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatJSON   Format = "json"
+)
+
+// ParseFormat はフラグなどで指定された形式名をFormatに変換します。
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown format %q (expected csv, ndjson or json)", name)
+	}
+}
+
+// DetectFormat はファイルの拡張子から形式を判定します。
+func DetectFormat(filename string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	if ext == "" {
+		return "", fmt.Errorf("cannot detect format of %q: no file extension", filename)
+	}
+	return ParseFormat(ext)
+}
+
+// ResolveFormat は明示的な指定があればそれを、なければ拡張子から判定した形式を返します。
+func ResolveFormat(name string, filename string) (Format, error) {
+	if name != "" {
+		return ParseFormat(name)
+	}
+	return DetectFormat(filename)
+}
+
+// Write は指定した形式で漫画データを書き出します。
+func Write(w io.Writer, format Format, comics []entity.Comic) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, comics)
+	case FormatNDJSON:
+		return WriteNDJSON(w, comics)
+	case FormatJSON:
+		return WriteJSON(w, comics)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// Read は指定した形式で漫画データを読み込み、形式によらず同じ検証を行います。
+func Read(r io.Reader, format Format) ([]entity.Comic, error) {
+	var comics []entity.Comic
+	var err error
+	switch format {
+	case FormatCSV:
+		comics, err = ReadCSV(r)
+	case FormatNDJSON:
+		comics, err = ReadNDJSON(r)
+	case FormatJSON:
+		comics, err = ReadJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Validate(comics); err != nil {
+		return nil, err
+	}
+	return comics, nil
+}
+
+// WriteFile は漫画データをファイルに書き出します。
+func WriteFile(filename string, format Format, comics []entity.Comic) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := Write(file, format, comics); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadFile はファイルから漫画データを読み込みます。
+func ReadFile(filename string, format Format) ([]entity.Comic, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file, format)
+}
+
+// ReadFileColumns はReadFileと同じようにファイルを読み込み、CSVの場合はヘッダーにあった列の名前も返します。
+// JSONは全ての列を持つとみなし、nilを返します。Mergeと組み合わせて既存のデータに重ねるために使います。
+func ReadFileColumns(filename string, format Format) ([]entity.Comic, []string, error) {
+	if format != FormatCSV {
+		comics, err := ReadFile(filename, format)
+		return comics, nil, err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	comics, columns, err := ReadCSVColumns(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := Validate(comics); err != nil {
+		return nil, nil, err
+	}
+	return comics, columns, nil
+}
@@ -0,0 +1,87 @@
+package comicio
+
+import (
+	"bufio"
+	"bytes"
+	"comic-summaries/entity"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteNDJSON は漫画データを1行に1件ずつJSONとして書き出します。
+func WriteNDJSON(w io.Writer, comics []entity.Comic) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, comic := range comics {
+		if err := encoder.Encode(comic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadNDJSON はWriteNDJSONで書き出したファイルを読み込みます。空行は読み飛ばします。
+func ReadNDJSON(r io.Reader) ([]entity.Comic, error) {
+	reader := bufio.NewReader(r)
+
+	var comics []entity.Comic
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			var comic entity.Comic
+			if err := decodeStrict(trimmed, &comic); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			comics = append(comics, comic)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return comics, nil
+}
+
+// WriteJSON は漫画データを整形済みのJSON配列として書き出します。
+func WriteJSON(w io.Writer, comics []entity.Comic) error {
+	if comics == nil {
+		comics = []entity.Comic{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(comics)
+}
+
+// ReadJSON はWriteJSONで書き出したJSON配列を読み込みます。
+func ReadJSON(r io.Reader) ([]entity.Comic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var comics []entity.Comic
+	if err := decodeStrict(data, &comics); err != nil {
+		return nil, err
+	}
+	return comics, nil
+}
+
+// decodeStrict は未知のフィールドを含むJSONをエラーとしてデコードします。
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package comicio
+
+import (
+	"comic-summaries/entity"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate はインポートする漫画データを検証し、問題をまとめてエラーとして返します。
+func Validate(comics []entity.Comic) error {
+	var errs []error
+	seen := make(map[int]int, len(comics))
+
+	for i, comic := range comics {
+		if comic.ID <= 0 {
+			errs = append(errs, fmt.Errorf("record %d: ID must be positive, got %d", i+1, comic.ID))
+		} else if prev, ok := seen[comic.ID]; ok {
+			errs = append(errs, fmt.Errorf("record %d: duplicate ID %d (also in record %d)", i+1, comic.ID, prev))
+		} else {
+			seen[comic.ID] = i + 1
+		}
+
+		if strings.TrimSpace(comic.Title) == "" {
+			errs = append(errs, fmt.Errorf("record %d: title is empty", i+1))
+		}
+		if comic.Status != "" && !entity.ValidStatus(comic.Status) {
+			errs = append(errs, fmt.Errorf("record %d: unknown status %q", i+1, comic.Status))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// StampImported はファイルから読み込んだ漫画データに来歴を補います。
+// エクスポートしたデータを読み込み直した場合に元の来歴を失わないよう、設定済みの値は変更しません。
+func StampImported(comics []entity.Comic, now time.Time) {
+	for i := range comics {
+		comic := &comics[i]
+		if comic.Source == "" {
+			comic.Source = entity.SourceImport
+		}
+		if comic.CreatedAt.IsZero() {
+			comic.CreatedAt = now.UTC()
+		}
+		if comic.UpdatedAt.IsZero() {
+			comic.UpdatedAt = now.UTC()
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// LoadAWS はaws-sdk-go-v2の設定を生成します。アクセスキーを指定した場合はそれを使います。
+func (a AWS) LoadAWS(ctx context.Context) (aws.Config, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(a.Region)}
+	if a.AccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(a.AccessKeyID, a.SecretAccessKey, "")))
+	}
+	return awsconfig.LoadDefaultConfig(ctx, opts...)
+}
+
+// NewDynamoDBClient はaws-sdk-go-v2のDynamoDBクライアントを生成します。DynamoDBEndpointを指定した場合はそこに接続します。
+func (a AWS) NewDynamoDBClient(ctx context.Context) (*dynamodb.Client, error) {
+	cfg, err := a.LoadAWS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if a.DynamoDBEndpoint != "" {
+			o.BaseEndpoint = aws.String(a.DynamoDBEndpoint)
+		}
+	}), nil
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"comic-summaries/imagestore"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCloudFrontURL はIMAGE_STOREがs3でIMAGE_BASE_URLを指定しない場合の画像の配信元です。
+const DefaultCloudFrontURL = "https://d3pqvcltup9bej.cloudfront.net"
+
+// Config はサーバーとツールが共有する設定です。
+type Config struct {
+	// Env は実行環境です。"dev" の場合は開発用の設定を許可します。
+	Env              string
+	Port             int
+	Server           Server
+	FrontendEndpoint string
+	// Repository は "dynamodb" または "memory" です。
+	Repository     string
+	MemorySeedFile string
+	AdminToken     string
+	AWS            AWS
+	// RemoteAWSRegion はローカルのデータをコピーする先のDynamoDBのリージョンです。
+	RemoteAWSRegion string
+	Image           Image
+	OpenAIAPIKey    string
+
+	// sources は各設定値をどこから読み込んだかです。環境変数名をキーにします。
+	sources map[string]string
+}
+
+// AWS はAWSへの接続の設定です。アクセスキーを指定しない場合はAWS SDKの既定の認証情報を使います。
+type AWS struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// DynamoDBEndpoint はDynamoDB Localなどに接続する場合のエンドポイントです。
+	DynamoDBEndpoint string
+}
+
+// Server はAPIサーバーのタイムアウトの設定です。0のタイムアウトは無制限です。
+type Server struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout は終了時に処理中のリクエストを待つ時間です。過ぎた場合は残りの接続を切ります。
+	ShutdownTimeout time.Duration
+	// HealthCheckTimeout は/readyzで依存先を1つ確認する時間の上限です。
+	HealthCheckTimeout time.Duration
+}
+
+// Image は画像の保存先と縮小した画像のキャッシュの設定です。
+type Image struct {
+	Store         string
+	Dir           string
+	BaseURL       string
+	Bucket        string
+	S3Endpoint    string
+	CacheDir      string
+	CacheMaxBytes int64
+}
+
+// field は1つの設定値です。環境変数、設定ファイル、コマンドライン引数で同じ値を指定できます。
+type field struct {
+	env    string
+	flag   string
+	def    string
+	usage  string
+	secret bool
+	get    func(c *Config) string
+	set    func(c *Config, value string) error
+}
+
+func stringField(env string, flag string, def string, usage string, ptr func(c *Config) *string) field {
+	return field{
+		env:   env,
+		flag:  flag,
+		def:   def,
+		usage: usage,
+		get:   func(c *Config) string { return *ptr(c) },
+		set: func(c *Config, value string) error {
+			*ptr(c) = value
+			return nil
+		},
+	}
+}
+
+func durationField(env string, flag string, def time.Duration, usage string, ptr func(c *Config) *time.Duration) field {
+	return field{
+		env:   env,
+		flag:  flag,
+		def:   def.String(),
+		usage: usage,
+		get:   func(c *Config) string { return ptr(c).String() },
+		set: func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return fmt.Errorf("must be a non-negative duration such as 30s, got %q", value)
+			}
+			*ptr(c) = d
+			return nil
+		},
+	}
+}
+
+func secretField(env string, flag string, usage string, ptr func(c *Config) *string) field {
+	f := stringField(env, flag, "", usage, ptr)
+	f.secret = true
+	return f
+}
+
+// fields は全ての設定値です。表示もこの順に並べます。
+var fields = []field{
+	stringField("GO_ENV", "env", "", "runtime environment; dev relaxes checks meant for production", func(c *Config) *string { return &c.Env }),
+	{
+		env:   "PORT",
+		flag:  "port",
+		def:   "1323",
+		usage: "port the API server listens on",
+		get:   func(c *Config) string { return strconv.Itoa(c.Port) },
+		set: func(c *Config, value string) error {
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 || port > 65535 {
+				return fmt.Errorf("must be a port number between 1 and 65535, got %q", value)
+			}
+			c.Port = port
+			return nil
+		},
+	},
+	durationField("SERVER_READ_HEADER_TIMEOUT", "read-header-timeout", 10*time.Second, "time limit for reading request headers", func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout }),
+	durationField("SERVER_READ_TIMEOUT", "read-timeout", 30*time.Second, "time limit for reading a whole request", func(c *Config) *time.Duration { return &c.Server.ReadTimeout }),
+	durationField("SERVER_WRITE_TIMEOUT", "write-timeout", time.Minute, "time limit for writing a response, including resizing images", func(c *Config) *time.Duration { return &c.Server.WriteTimeout }),
+	durationField("SERVER_IDLE_TIMEOUT", "idle-timeout", 2*time.Minute, "how long idle keep-alive connections are kept open", func(c *Config) *time.Duration { return &c.Server.IdleTimeout }),
+	durationField("SHUTDOWN_TIMEOUT", "shutdown-timeout", 30*time.Second, "how long in-flight requests may run after SIGTERM/SIGINT", func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout }),
+	durationField("HEALTH_CHECK_TIMEOUT", "health-check-timeout", 2*time.Second, "time limit for each dependency check of /readyz", func(c *Config) *time.Duration { return &c.Server.HealthCheckTimeout }),
+	stringField("FRONTEND_ENDPOINT", "frontend-endpoint", "", "origin of the frontend allowed by CORS", func(c *Config) *string { return &c.FrontendEndpoint }),
+	stringField("REPOSITORY", "repository", "dynamodb", "comic storage: dynamodb or memory", func(c *Config) *string { return &c.Repository }),
+	stringField("MEMORY_SEED_FILE", "memory-seed-file", "", "CSV/NDJSON/JSON file loaded into the memory repository", func(c *Config) *string { return &c.MemorySeedFile }),
+	secretField("ADMIN_TOKEN", "admin-token", "bearer token for the admin routes and /metrics; they are disabled when empty", func(c *Config) *string { return &c.AdminToken }),
+	stringField("AWS_REGION", "aws-region", "", "AWS region", func(c *Config) *string { return &c.AWS.Region }),
+	secretField("AWS_ACCESS_KEY_ID", "aws-access-key-id", "AWS access key ID (default: the SDK credential chain)", func(c *Config) *string { return &c.AWS.AccessKeyID }),
+	secretField("AWS_SECRET_ACCESS_KEY", "aws-secret-access-key", "AWS secret access key", func(c *Config) *string { return &c.AWS.SecretAccessKey }),
+	stringField("DYNAMODB_ENDPOINT", "dynamodb-endpoint", "", "DynamoDB endpoint, e.g. http://localhost:8000 for DynamoDB Local", func(c *Config) *string { return &c.AWS.DynamoDBEndpoint }),
+	stringField("REMOTE_AWS_REGION", "remote-aws-region", "", "region of the remote DynamoDB that local data is copied to", func(c *Config) *string { return &c.RemoteAWSRegion }),
+	stringField("IMAGE_STORE", "image-store", "local", "image store: local or s3", func(c *Config) *string { return &c.Image.Store }),
+	stringField("IMAGE_DIR", "image-dir", "images", "directory of the local image store", func(c *Config) *string { return &c.Image.Dir }),
+	stringField("IMAGE_BASE_URL", "image-base-url", "", "URL prefix of stored images (default: /images, or CloudFront for s3)", func(c *Config) *string { return &c.Image.BaseURL }),
+	stringField("IMAGE_BUCKET", "image-bucket", "comic-summaries", "bucket of the s3 image store", func(c *Config) *string { return &c.Image.Bucket }),
+	stringField("IMAGE_S3_ENDPOINT", "image-s3-endpoint", "", "endpoint of an S3-compatible storage such as MinIO", func(c *Config) *string { return &c.Image.S3Endpoint }),
+	stringField("IMAGE_CACHE_DIR", "image-cache-dir", "", "directory of the resized image cache (default: under the OS temp directory)", func(c *Config) *string { return &c.Image.CacheDir }),
+	{
+		env:   "IMAGE_CACHE_MAX_BYTES",
+		flag:  "image-cache-max-bytes",
+		def:   strconv.Itoa(256 << 20),
+		usage: "size limit of the resized image cache in bytes",
+		get:   func(c *Config) string { return strconv.FormatInt(c.Image.CacheMaxBytes, 10) },
+		set: func(c *Config, value string) error {
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size <= 0 {
+				return fmt.Errorf("must be a positive number of bytes, got %q", value)
+			}
+			c.Image.CacheMaxBytes = size
+			return nil
+		},
+	},
+	secretField("OPENAI_API_KEY", "openai-api-key", "OpenAI API key used to generate summaries", func(c *Config) *string { return &c.OpenAIAPIKey }),
+}
+
+// Validate は値の組み合わせを検証します。全ての問題をまとめて返します。
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Repository {
+	case "dynamodb":
+		if c.AWS.Region == "" {
+			errs = append(errs, errors.New("AWS_REGION is required when REPOSITORY is dynamodb"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("REPOSITORY must be dynamodb or memory, got %q", c.Repository))
+	}
+	if c.Server.ShutdownTimeout == 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be longer than 0s"))
+	}
+	if c.Server.HealthCheckTimeout == 0 {
+		errs = append(errs, errors.New("HEALTH_CHECK_TIMEOUT must be longer than 0s"))
+	}
+	if (c.AWS.AccessKeyID == "") != (c.AWS.SecretAccessKey == "") {
+		errs = append(errs, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together"))
+	}
+	switch c.Image.Store {
+	case "local":
+	case "s3":
+		if c.AWS.Region == "" {
+			errs = append(errs, errors.New("AWS_REGION is required when IMAGE_STORE is s3"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("IMAGE_STORE must be local or s3, got %q", c.Image.Store))
+	}
+	for _, u := range []struct{ name, value string }{
+		{"FRONTEND_ENDPOINT", c.FrontendEndpoint},
+		{"DYNAMODB_ENDPOINT", c.AWS.DynamoDBEndpoint},
+		{"IMAGE_S3_ENDPOINT", c.Image.S3Endpoint},
+	} {
+		if u.value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(u.value); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an absolute URL such as http://localhost:8000, got %q", u.name, u.value))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Require は指定した環境変数名の設定値が空でないことを検証します。プログラムごとに必要な値の確認に使います。
+func (c *Config) Require(names ...string) error {
+	var errs []error
+	for _, name := range names {
+		f, ok := lookup(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown setting %s", name))
+			continue
+		}
+		if f.get(c) == "" {
+			errs = append(errs, fmt.Errorf("%s is required: set it in the environment, the config file or with -%s", f.env, f.flag))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ImageStore は画像の保存先の設定を返します。
+func (c *Config) ImageStore() imagestore.Config {
+	return imagestore.Config{
+		Backend:  c.Image.Store,
+		Dir:      c.Image.Dir,
+		BaseURL:  c.Image.BaseURL,
+		Bucket:   c.Image.Bucket,
+		Region:   c.AWS.Region,
+		Endpoint: c.Image.S3Endpoint,
+	}
+}
+
+// String は有効な設定を1行に1つずつ、読み込み元と共に返します。秘密の値は伏せます。
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, f := range fields {
+		value := f.get(c)
+		if f.secret && value != "" {
+			value = "********"
+		}
+		source := c.sources[f.env]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(&b, "%s=%s (%s)\n", f.env, value, source)
+	}
+	return b.String()
+}
+
+func lookup(name string) (field, bool) {
+	for _, f := range fields {
+		if f.env == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Options はプログラムごとの読み込み方です。
+type Options struct {
+	// File は既定の設定ファイル (.envと同じ形式) です。CONFIG_FILEか-configで別のファイルを指定できます。
+	// 既定のファイルは開発環境 (GO_ENV=dev) でのみ読み込み、存在しない場合は読み込まずに続けます。
+	File string
+	// Defaults はプログラムごとの既定値です。環境変数名をキーにします。
+	Defaults map[string]string
+}
+
+// ServerOptions はAPIサーバーの読み込み方です。
+var ServerOptions = Options{File: ".env"}
+
+// ToolOptions はtools/のコマンドの読み込み方です。tools/から実行するため、開発環境ではサーバーと同じ.envを読み込み、
+// ローカルの画像はリポジトリ直下で実行するサーバーと同じディレクトリ (../images) に保存します。
+// DynamoDB Localは.envか環境変数で指定してください。
+var ToolOptions = Options{
+	File: "../.env",
+	Defaults: map[string]string{
+		"IMAGE_DIR": "../images",
+	},
+}
+
+// Loader はコマンドライン引数を登録し、設定を読み込みます。
+type Loader struct {
+	fs          *flag.FlagSet
+	opts        Options
+	file        *string
+	printConfig *bool
+	values      map[string]*string
+}
+
+// NewLoader はfsに全ての設定値の引数と -config、-print-config を登録します。fsをParseしてからLoadを呼び出してください。
+func NewLoader(fs *flag.FlagSet, opts Options) *Loader {
+	l := &Loader{
+		fs:     fs,
+		opts:   opts,
+		values: map[string]*string{},
+	}
+	l.file = fs.String("config", "", fmt.Sprintf("config file in .env format (default: %s if it exists and GO_ENV is dev, or $CONFIG_FILE)", opts.File))
+	l.printConfig = fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	for _, f := range fields {
+		l.values[f.env] = fs.String(f.flag, "", fmt.Sprintf("%s (env %s)", f.usage, f.env))
+	}
+	return l
+}
+
+// Load は既定値、設定ファイル、環境変数、コマンドライン引数の順に重ねて設定を読み込み、検証します。
+// 設定ファイルの値は環境変数にも設定するため、AWS SDKの既定の認証情報なども設定ファイルの値を使えます。
+func (l *Loader) Load() (*Config, error) {
+	fromFile, err := l.loadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	explicit := map[string]bool{}
+	l.fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	c := &Config{sources: map[string]string{}}
+	var errs []error
+	for _, f := range fields {
+		value, source := f.def, ""
+		if def, ok := l.opts.Defaults[f.env]; ok {
+			value = def
+		}
+		if v := os.Getenv(f.env); v != "" {
+			value, source = v, "env"
+			if fromFile[f.env] {
+				source = "file"
+			}
+		}
+		if explicit[f.flag] {
+			value, source = *l.values[f.env], "flag"
+		}
+		if err := f.set(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+		}
+		c.sources[f.env] = source
+	}
+
+	// 他の設定値から決まる既定値
+	if c.Image.BaseURL == "" {
+		c.Image.BaseURL = "/images"
+		if c.Image.Store == "s3" {
+			c.Image.BaseURL = DefaultCloudFrontURL
+		}
+	}
+	if c.Image.CacheDir == "" {
+		c.Image.CacheDir = filepath.Join(os.TempDir(), "comic-summaries-image-cache")
+	}
+
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return c, nil
+}
+
+// loadFile は設定ファイルを読み込み、まだ設定されていない環境変数に設定します。戻り値は設定ファイルから設定した変数名です。
+func (l *Loader) loadFile() (map[string]bool, error) {
+	filename, explicit := *l.file, true
+	if filename == "" {
+		filename = os.Getenv("CONFIG_FILE")
+	}
+	if filename == "" {
+		// 本番環境で手元の.envを読み込まないよう、既定のファイルは開発環境でのみ使う
+		if l.env() != "dev" {
+			return nil, nil
+		}
+		filename, explicit = l.opts.File, false
+	}
+	if filename == "" {
+		return nil, nil
+	}
+
+	values, err := godotenv.Read(filename)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config file %s: %w", filename, err)
+	}
+
+	fromFile := map[string]bool{}
+	for key, value := range values {
+		if _, ok := os.LookupEnv(key); ok {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return nil, err
+		}
+		fromFile[key] = true
+	}
+	return fromFile, nil
+}
+
+// env は設定ファイルを読み込む前の実行環境です。-envかGO_ENVの値を返します。
+func (l *Loader) env() string {
+	if env := *l.values["GO_ENV"]; env != "" {
+		return env
+	}
+	return os.Getenv("GO_ENV")
+}
+
+// MustLoad はfsをParse済みのLoaderで設定を読み込みます。設定が正しくない場合は問題を全て表示して終了します。
+// -print-config が指定された場合は有効な設定を表示して終了します。
+func (l *Loader) MustLoad() *Config {
+	c, err := l.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if *l.printConfig {
+		fmt.Print(c)
+		os.Exit(0)
+	}
+	return c
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeEnvFile は既定の設定ファイルとしてDYNAMODB_ENDPOINTを設定したファイルを書き出します。
+func writeEnvFile(t *testing.T) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(filename, []byte("DYNAMODB_ENDPOINT=http://localhost:8000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func load(t *testing.T, opts Options, args ...string) *Config {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(fs, opts)
+	if err := fs.Parse(append([]string{"-repository", "memory"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	c, err := l.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLoadReadsDefaultFileOnlyInDev(t *testing.T) {
+	filename := writeEnvFile(t)
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want string
+	}{
+		{"production", "", nil, ""},
+		{"GO_ENV=dev", "dev", nil, "http://localhost:8000"},
+		{"-env dev", "", []string{"-env", "dev"}, "http://localhost:8000"},
+		{"GO_ENV=production", "production", nil, ""},
+		// 明示したファイルは実行環境に関わらず読み込む
+		{"-config", "", []string{"-config", filename}, "http://localhost:8000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GO_ENV", tt.env)
+			t.Setenv("CONFIG_FILE", "")
+			// 設定済みの環境変数はファイルで上書きしないため、未設定にする。テストの後はt.Setenvが元に戻す
+			t.Setenv("DYNAMODB_ENDPOINT", "")
+			os.Unsetenv("DYNAMODB_ENDPOINT")
+
+			c := load(t, Options{File: filename}, tt.args...)
+			if c.AWS.DynamoDBEndpoint != tt.want {
+				t.Errorf("DYNAMODB_ENDPOINT = %q, want %q", c.AWS.DynamoDBEndpoint, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolOptionsDefaults(t *testing.T) {
+	t.Setenv("GO_ENV", "")
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DYNAMODB_ENDPOINT", "")
+	t.Setenv("IMAGE_DIR", "")
+
+	c := load(t, ToolOptions)
+	if c.AWS.DynamoDBEndpoint != "" {
+		t.Errorf("DYNAMODB_ENDPOINT = %q, want no default", c.AWS.DynamoDBEndpoint)
+	}
+	// tools/から実行するため、リポジトリ直下のサーバーと同じディレクトリを指す
+	if c.Image.Dir != "../images" {
+		t.Errorf("IMAGE_DIR = %q, want ../images", c.Image.Dir)
+	}
+	if server := load(t, ServerOptions); server.Image.Dir != "images" {
+		t.Errorf("server IMAGE_DIR = %q, want images", server.Image.Dir)
+	}
+}
@@ -0,0 +1,268 @@
+package controller
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/usecase"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type IAdminController interface {
+	GetComic(c echo.Context) error
+	GetAllComics(c echo.Context) error
+	UpdateComic(c echo.Context) error
+	GetRevisions(c echo.Context) error
+	DiffRevisions(c echo.Context) error
+	Rollback(c echo.Context) error
+	GetReviewQueue(c echo.Context) error
+	Submit(c echo.Context) error
+	Approve(c echo.Context) error
+	Reject(c echo.Context) error
+}
+
+type adminController struct {
+	cu  usecase.IComicUsecase
+	ru  usecase.IRevisionUsecase
+	rvu usecase.IReviewUsecase
+}
+
+func NewAdminController(cu usecase.IComicUsecase, ru usecase.IRevisionUsecase, rvu usecase.IReviewUsecase) IAdminController {
+	return &adminController{cu, ru, rvu}
+}
+
+// adminAuthorHeader は変更履歴に記録する編集者名を渡すヘッダーです。
+const adminAuthorHeader = "X-Admin-User"
+
+// author はリクエストの編集者名を返します。ヘッダーがない場合は "admin" とします。
+func author(c echo.Context) string {
+	if name := c.Request().Header.Get(adminAuthorHeader); name != "" {
+		return name
+	}
+	return "admin"
+}
+
+// adminComicView は編集者向けに、要約が機械生成かどうかと更新からの経過日数を付けた漫画データです。
+type adminComicView struct {
+	*entity.Comic
+	MachineGenerated bool `json:"machine_generated"`
+	AgeDays          *int `json:"age_days"`
+}
+
+func newAdminComicView(comic *entity.Comic, now time.Time) adminComicView {
+	view := adminComicView{
+		Comic:            comic,
+		MachineGenerated: comic.MachineGenerated(),
+	}
+	if !comic.UpdatedAt.IsZero() {
+		days := int(now.Sub(comic.UpdatedAt).Hours() / 24)
+		view.AgeDays = &days
+	}
+	return view
+}
+
+func (ac *adminController) GetComic(c echo.Context) error {
+	id := c.Param("id")
+	comic, err := ac.cu.GetComicByID(c.Request().Context(), id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if comic == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Comic not found"})
+	}
+	return c.JSON(http.StatusOK, newAdminComicView(comic, time.Now()))
+}
+
+func (ac *adminController) GetAllComics(c echo.Context) error {
+	pageParam := c.QueryParam("page")
+	page, err := strconv.Atoi(pageParam)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	comics, lastEvaluatedKey, err := ac.cu.GetAllComics(c.Request().Context(), page)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	now := time.Now()
+	views := make([]adminComicView, 0, len(comics))
+	for _, comic := range comics {
+		views = append(views, newAdminComicView(comic, now))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"comics":           views,
+		"lastEvaluatedKey": lastEvaluatedKey,
+	})
+}
+
+// updateComicRequest は要約の編集内容と、履歴に残す編集理由です。
+type updateComicRequest struct {
+	usecase.ComicEdit
+	Reason string `json:"reason"`
+}
+
+func (ac *adminController) UpdateComic(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid comic ID"})
+	}
+	var req updateComicRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+	}
+
+	comic, err := ac.cu.EditComic(c.Request().Context(), id, req.ComicEdit, author(c), req.Reason)
+	if errors.Is(err, usecase.ErrComicNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Comic not found"})
+	}
+	if errors.Is(err, usecase.ErrConcurrentEdit) {
+		return c.JSON(http.StatusConflict, map[string]string{"message": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, newAdminComicView(comic, time.Now()))
+}
+
+func (ac *adminController) GetRevisions(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid comic ID"})
+	}
+	revisions, err := ac.ru.ListRevisions(c.Request().Context(), id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, revisions)
+}
+
+func (ac *adminController) DiffRevisions(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid comic ID"})
+	}
+	from, fromErr := strconv.Atoi(c.QueryParam("from"))
+	to, toErr := strconv.Atoi(c.QueryParam("to"))
+	if fromErr != nil || toErr != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "from and to query parameters are required"})
+	}
+
+	changes, err := ac.ru.DiffRevisions(c.Request().Context(), id, from, to)
+	if errors.Is(err, usecase.ErrRevisionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"changes": changes,
+	})
+}
+
+// rollbackRequest はロールバックの理由です。
+type rollbackRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (ac *adminController) Rollback(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid comic ID"})
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid revision"})
+	}
+	var req rollbackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+	}
+
+	comic, err := ac.ru.Rollback(c.Request().Context(), id, revision, author(c), req.Reason)
+	if errors.Is(err, usecase.ErrRevisionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": err.Error()})
+	}
+	if errors.Is(err, usecase.ErrConcurrentEdit) {
+		return c.JSON(http.StatusConflict, map[string]string{"message": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, newAdminComicView(comic, time.Now()))
+}
+
+func (ac *adminController) GetReviewQueue(c echo.Context) error {
+	status := c.QueryParam("status")
+	if status == "" {
+		status = entity.StatusInReview
+	}
+	comics, err := ac.rvu.ListQueue(c.Request().Context(), status)
+	if errors.Is(err, usecase.ErrUnknownStatus) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	now := time.Now()
+	views := make([]adminComicView, 0, len(comics))
+	for _, comic := range comics {
+		views = append(views, newAdminComicView(comic, now))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": status,
+		"comics": views,
+	})
+}
+
+// reviewRequest はレビューのコメントです。
+type reviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+func (ac *adminController) Submit(c echo.Context) error {
+	return ac.review(c, func(id int, reviewer string, comment string) (*entity.Comic, error) {
+		return ac.rvu.Submit(c.Request().Context(), id, reviewer)
+	})
+}
+
+func (ac *adminController) Approve(c echo.Context) error {
+	return ac.review(c, func(id int, reviewer string, comment string) (*entity.Comic, error) {
+		return ac.rvu.Approve(c.Request().Context(), id, reviewer, comment)
+	})
+}
+
+func (ac *adminController) Reject(c echo.Context) error {
+	return ac.review(c, func(id int, reviewer string, comment string) (*entity.Comic, error) {
+		return ac.rvu.Reject(c.Request().Context(), id, reviewer, comment)
+	})
+}
+
+// review はレビュー操作のリクエストを読み取ってactionを実行し、エラーをステータスコードに変換します。
+func (ac *adminController) review(c echo.Context, action func(id int, reviewer string, comment string) (*entity.Comic, error)) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid comic ID"})
+	}
+	var req reviewRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+	}
+
+	comic, err := action(id, author(c), req.Comment)
+	switch {
+	case errors.Is(err, usecase.ErrComicNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Comic not found"})
+	case errors.Is(err, usecase.ErrInvalidTransition) || errors.Is(err, usecase.ErrConcurrentEdit):
+		return c.JSON(http.StatusConflict, map[string]string{"message": err.Error()})
+	case errors.Is(err, usecase.ErrCommentRequired):
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, newAdminComicView(comic, time.Now()))
+}
@@ -25,7 +25,7 @@ func NewComicController(cu usecase.IComicUsecase) IComicController {
 
 func (cc *comicController) GetComic(c echo.Context) error {
 	id := c.Param("id")
-	comic, err := cc.cu.GetComicByID(c.Request().Context(), id)
+	comic, err := cc.cu.GetPublishedComicByID(c.Request().Context(), id)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -38,7 +38,7 @@ func (cc *comicController) GetAllComics(c echo.Context) error {
 	if err != nil || page < 1 {
 		page = 1
 	}
-	comics, lastEvaluatedKey, err := cc.cu.GetAllComics(c.Request().Context(), page)
+	comics, lastEvaluatedKey, err := cc.cu.GetPublishedComics(c.Request().Context(), page)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -53,7 +53,7 @@ func (cc *comicController) SearchComics(c echo.Context) error {
 	if title == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Title query parameter is required"})
 	}
-	comics, err := cc.cu.SearchComicsByTitle(c.Request().Context(), title)
+	comics, err := cc.cu.SearchPublishedComicsByTitle(c.Request().Context(), title)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -61,7 +61,7 @@ func (cc *comicController) SearchComics(c echo.Context) error {
 }
 
 func (cc *comicController) GetTotalCount(c echo.Context) error {
-	totalCount, err := cc.cu.GetTotalCount(c.Request().Context())
+	totalCount, err := cc.cu.GetPublishedCount(c.Request().Context())
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
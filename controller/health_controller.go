@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"comic-summaries/usecase"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type IHealthController interface {
+	Healthz(c echo.Context) error
+	Readyz(c echo.Context) error
+}
+
+type healthController struct {
+	hu usecase.IHealthUsecase
+}
+
+func NewHealthController(hu usecase.IHealthUsecase) IHealthController {
+	return &healthController{hu}
+}
+
+// Healthz はプロセスが応答できることだけを返します。依存先が落ちていても再起動しても直らないため確認しません。
+func (hc *healthController) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz は依存先ごとの確認の結果を返します。1つでも失敗した場合は503を返し、トラフィックを外してもらいます。
+func (hc *healthController) Readyz(c echo.Context) error {
+	readiness := hc.hu.Readiness(c.Request().Context())
+	c.Response().Header().Set("Cache-Control", "no-store")
+	if !readiness.Ready() {
+		return c.JSON(http.StatusServiceUnavailable, readiness)
+	}
+	return c.JSON(http.StatusOK, readiness)
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"comic-summaries/imagepipeline"
+	"comic-summaries/imagestore"
+	"comic-summaries/usecase"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// immutableCacheControl は画像に付けるCache-Controlです。画像のキーは保存のたびに新しく作るため、同じURLの内容は変わりません。
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+type IImageController interface {
+	GetImage(c echo.Context) error
+}
+
+type imageController struct {
+	iu usecase.IImageUsecase
+}
+
+func NewImageController(iu usecase.IImageUsecase) IImageController {
+	return &imageController{iu}
+}
+
+// GetImage は画像を返します。w・h・fitのいずれかを指定した場合は元画像から縮小したJPEGを返します。
+func (ic *imageController) GetImage(c echo.Context) error {
+	key := c.Param("*")
+	if c.QueryParam("w") == "" && c.QueryParam("h") == "" && c.QueryParam("fit") == "" {
+		return ic.getOriginal(c, key)
+	}
+
+	var resize usecase.ImageResize
+	var err error
+	if w := c.QueryParam("w"); w != "" {
+		if resize.Width, err = strconv.Atoi(w); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid width"})
+		}
+	}
+	if h := c.QueryParam("h"); h != "" {
+		if resize.Height, err = strconv.Atoi(h); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid height"})
+		}
+	}
+	resize.Fit = c.QueryParam("fit")
+
+	resized, err := ic.iu.GetResized(c.Request().Context(), key, resize)
+	switch {
+	case errors.Is(err, imagestore.ErrNotFound) || errors.Is(err, imagestore.ErrInvalidKey):
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Image not found"})
+	case errors.Is(err, usecase.ErrInvalidImageSize):
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	case errors.Is(err, imagepipeline.ErrUnsupported):
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"message": err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	etag := `"` + resized.ETag + `"`
+	header := c.Response().Header()
+	header.Set("Cache-Control", immutableCacheControl)
+	header.Set("ETag", etag)
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, resized.ContentType, resized.Data)
+}
+
+func (ic *imageController) getOriginal(c echo.Context, key string) error {
+	object, err := ic.iu.GetOriginal(c.Request().Context(), key)
+	if errors.Is(err, imagestore.ErrNotFound) || errors.Is(err, imagestore.ErrInvalidKey) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Image not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	defer object.Close()
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", immutableCacheControl)
+	if object.Size >= 0 {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(object.Size, 10))
+	}
+	if !object.ModTime.IsZero() {
+		header.Set(echo.HeaderLastModified, object.ModTime.UTC().Format(http.TimeFormat))
+	}
+	return c.Stream(http.StatusOK, object.ContentType, object)
+}
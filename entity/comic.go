@@ -1,5 +1,32 @@
 package entity
 
+import (
+	"sort"
+	"time"
+)
+
+// 要約の出所を表すSourceの値です。
+const (
+	// SourceImport はCSVなどのファイルからインポートした要約です。
+	SourceImport = "import"
+	// SourceLLM はLLMが生成した要約です。
+	SourceLLM = "llm"
+	// SourceAdmin は管理画面で編集された要約です。
+	SourceAdmin = "admin"
+)
+
+// レビューの状態を表すStatusの値です。draft → in_review → published / rejected の順に遷移します。
+const (
+	// StatusDraft はレビュー前の下書きです。
+	StatusDraft = "draft"
+	// StatusInReview はレビュー待ちです。
+	StatusInReview = "in_review"
+	// StatusPublished は公開中です。
+	StatusPublished = "published"
+	// StatusRejected はレビューで差し戻されました。
+	StatusRejected = "rejected"
+)
+
 // Comic は漫画のエンティティを表します。
 type Comic struct {
 	ID         int    `json:"id" dynamodbav:"ID"`
@@ -10,4 +37,124 @@ type Comic struct {
 	Genre      string `json:"genre" dynamodbav:"Genre"`
 	Characters string `json:"characters" dynamodbav:"Characters"`
 	ImagePath  string `json:"image_path" dynamodbav:"ImagePath"`
+	// Images はImagePathの画像から生成したサイズ・形式違いの画像です。
+	Images *ImageSet `json:"images,omitempty" dynamodbav:"Images,omitempty"`
+	// BlurHash と DominantColor は表紙を読み込むまでに表示するプレースホルダーです。DominantColorは "#rrggbb" 形式です。
+	BlurHash      string `json:"blurhash,omitempty" dynamodbav:"BlurHash,omitempty"`
+	DominantColor string `json:"dominant_color,omitempty" dynamodbav:"DominantColor,omitempty"`
+	// ImageBroken はcheck_imagesが画像を取得できないと記録したことを表します。フロントエンドは代わりの画像を表示できます。
+	ImageBroken bool `json:"image_broken,omitempty" dynamodbav:"ImageBroken,omitempty"`
+	// Model と PromptVersion は要約を生成したモデルとプロンプトのバージョンです。古いプロンプトで生成した要約を探すために使用します。
+	Model         string `json:"model,omitempty" dynamodbav:"Model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty" dynamodbav:"PromptVersion,omitempty"`
+	// 以下は書き込みのたびにTouchで更新する来歴です。
+	CreatedAt time.Time `json:"created_at" dynamodbav:"CreatedAt"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"UpdatedAt"`
+	Source    string    `json:"source,omitempty" dynamodbav:"Source,omitempty"`
+	SourceURL string    `json:"source_url,omitempty" dynamodbav:"SourceURL,omitempty"`
+	// Status はレビューの状態です。レビュー導入前のデータは空で、公開中として扱います。
+	Status        string `json:"status,omitempty" dynamodbav:"Status,omitempty"`
+	ReviewComment string `json:"review_comment,omitempty" dynamodbav:"ReviewComment,omitempty"`
+	ReviewedBy    string `json:"reviewed_by,omitempty" dynamodbav:"ReviewedBy,omitempty"`
+	// Aliases は同じ作品として取り込んだ別のタイトル表記です。
+	Aliases []string `json:"aliases,omitempty" dynamodbav:"Aliases,omitempty"`
+	// DuplicateOf は取り込み時に重複の可能性があると判定した既存の漫画のIDです。
+	DuplicateOf int `json:"duplicate_of,omitempty" dynamodbav:"DuplicateOf,omitempty"`
+	// Pending は公開中の漫画について再生成し、レビューを待っている要約です。承認されるまで公開中の要約は変わりません。
+	Pending *PendingSummary `json:"pending,omitempty" dynamodbav:"Pending,omitempty"`
+}
+
+// PendingSummary は承認されるまで公開しない、再生成した要約です。
+type PendingSummary struct {
+	Synopsis      string `json:"synopsis" dynamodbav:"Synopsis"`
+	Attraction    string `json:"attraction" dynamodbav:"Attraction"`
+	Spoilers      string `json:"spoilers" dynamodbav:"Spoilers"`
+	Genre         string `json:"genre" dynamodbav:"Genre"`
+	Characters    string `json:"characters" dynamodbav:"Characters"`
+	Model         string `json:"model,omitempty" dynamodbav:"Model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty" dynamodbav:"PromptVersion,omitempty"`
+}
+
+// NewPendingSummary はcomicの要約をレビュー待ちの要約として取り出します。
+func NewPendingSummary(comic *Comic) *PendingSummary {
+	return &PendingSummary{
+		Synopsis:      comic.Synopsis,
+		Attraction:    comic.Attraction,
+		Spoilers:      comic.Spoilers,
+		Genre:         comic.Genre,
+		Characters:    comic.Characters,
+		Model:         comic.Model,
+		PromptVersion: comic.PromptVersion,
+	}
+}
+
+// ApplyPending はレビュー待ちの要約で公開中の要約を置き換え、Pendingを空にします。レビュー待ちの要約がなければfalseを返します。
+func (c *Comic) ApplyPending() bool {
+	if c.Pending == nil {
+		return false
+	}
+	c.Synopsis = c.Pending.Synopsis
+	c.Attraction = c.Pending.Attraction
+	c.Spoilers = c.Pending.Spoilers
+	c.Genre = c.Pending.Genre
+	c.Characters = c.Pending.Characters
+	c.Model = c.Pending.Model
+	c.PromptVersion = c.Pending.PromptVersion
+	c.Pending = nil
+	return true
+}
+
+// Touch は書き込み時の来歴を記録します。CreatedAtは未設定の場合のみ設定します。
+func (c *Comic) Touch(source string, now time.Time) {
+	now = now.UTC()
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+	c.UpdatedAt = now
+	c.Source = source
+}
+
+// MachineGenerated はLLMが生成した要約であるかを返します。
+func (c *Comic) MachineGenerated() bool {
+	return c.Source == SourceLLM
+}
+
+// AddAlias は別名を追加します。タイトルと同じ表記や登録済みの別名は追加せず、falseを返します。
+func (c *Comic) AddAlias(alias string) bool {
+	if alias == "" || alias == c.Title {
+		return false
+	}
+	for _, a := range c.Aliases {
+		if a == alias {
+			return false
+		}
+	}
+	c.Aliases = append(c.Aliases, alias)
+	return true
+}
+
+// Published は公開中であるかを返します。Statusが空のデータも公開中とみなします。
+func (c *Comic) Published() bool {
+	return c.Status == "" || c.Status == StatusPublished
+}
+
+// AwaitingReview はレビュー待ちであるかを返します。公開中でも再生成した要約が承認を待っていればレビュー待ちです。
+func (c *Comic) AwaitingReview() bool {
+	return c.Status == StatusInReview || (c.Published() && c.Pending != nil)
+}
+
+// ValidStatus はStatusとして使える値であるかを返します。
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusDraft, StatusInReview, StatusPublished, StatusRejected:
+		return true
+	}
+	return false
+}
+
+// SortComicsByID は漫画をID昇順に並べ替えます。
+func SortComicsByID(comics []*Comic) {
+	sort.Slice(comics, func(i, j int) bool {
+		return comics[i].ID < comics[j].ID
+	})
 }
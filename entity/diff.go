@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// FieldChange は1つのフィールドの変更前と変更後の値です。
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Diff は2つの漫画データの内容を比較し、値が異なるフィールドを返します。来歴のフィールドは比較しません。
+func Diff(old *Comic, new *Comic) []FieldChange {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"Title", old.Title, new.Title},
+		{"Synopsis", old.Synopsis, new.Synopsis},
+		{"Attraction", old.Attraction, new.Attraction},
+		{"Spoilers", old.Spoilers, new.Spoilers},
+		{"Genre", old.Genre, new.Genre},
+		{"Characters", old.Characters, new.Characters},
+		{"ImagePath", old.ImagePath, new.ImagePath},
+		{"Images", imagesString(old.Images), imagesString(new.Images)},
+		{"BlurHash", old.BlurHash, new.BlurHash},
+		{"DominantColor", old.DominantColor, new.DominantColor},
+		{"ImageBroken", strconv.FormatBool(old.ImageBroken), strconv.FormatBool(new.ImageBroken)},
+		{"Model", old.Model, new.Model},
+		{"PromptVersion", old.PromptVersion, new.PromptVersion},
+		{"Status", old.Status, new.Status},
+		{"ReviewComment", old.ReviewComment, new.ReviewComment},
+		{"ReviewedBy", old.ReviewedBy, new.ReviewedBy},
+		{"Aliases", strings.Join(old.Aliases, "\n"), strings.Join(new.Aliases, "\n")},
+		{"DuplicateOf", strconv.Itoa(old.DuplicateOf), strconv.Itoa(new.DuplicateOf)},
+		{"Pending", pendingString(old.Pending), pendingString(new.Pending)},
+	}
+
+	var changes []FieldChange
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, FieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	return changes
+}
+
+// pendingString はレビュー待ちの要約を比較・表示用のJSONにします。レビュー待ちの要約がない場合は空文字列です。
+func pendingString(pending *PendingSummary) string {
+	if pending == nil {
+		return ""
+	}
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// imagesString は画像の一覧を比較・表示用のJSONにします。画像がない場合は空文字列です。
+func imagesString(images *ImageSet) string {
+	if images == nil {
+		return ""
+	}
+	data, err := json.Marshal(images)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
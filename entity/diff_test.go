@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	base := Comic{
+		ID:        1,
+		Title:     "漫画",
+		Status:    StatusPublished,
+		Images:    &ImageSet{Src: "a-480.jpg"},
+		Aliases:   []string{"別名"},
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name   string
+		change func(c *Comic)
+		want   []string
+	}{
+		{"no change", func(c *Comic) {}, nil},
+		{"provenance is ignored", func(c *Comic) { c.UpdatedAt = time.Now(); c.Source = SourceAdmin }, nil},
+		{"status", func(c *Comic) { c.Status = StatusDraft }, []string{"Status"}},
+		{"images", func(c *Comic) { c.Images = &ImageSet{Src: "b-480.jpg"} }, []string{"Images"}},
+		{"images removed", func(c *Comic) { c.Images = nil }, []string{"Images"}},
+		{"aliases", func(c *Comic) { c.Aliases = append(c.Aliases, "2つ目") }, []string{"Aliases"}},
+		{"several fields", func(c *Comic) { c.Synopsis = "新しい"; c.DuplicateOf = 2 }, []string{"Synopsis", "DuplicateOf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base
+			changed.Aliases = append([]string(nil), base.Aliases...)
+			tt.change(&changed)
+			var fields []string
+			for _, change := range Diff(&base, &changed) {
+				fields = append(fields, change.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("changed fields = %v, want %v", fields, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package entity
+
+// ImageVariant は表紙画像を1つの幅と形式で書き出したものです。
+type ImageVariant struct {
+	Width  int    `json:"width" dynamodbav:"Width"`
+	Height int    `json:"height" dynamodbav:"Height"`
+	URL    string `json:"url" dynamodbav:"URL"`
+}
+
+// ImageSource は同じ形式の画像の組です。SrcSetはimg要素やsource要素のsrcset属性にそのまま使えます。
+type ImageSource struct {
+	Type     string         `json:"type" dynamodbav:"Type"`
+	SrcSet   string         `json:"srcset" dynamodbav:"SrcSet"`
+	Variants []ImageVariant `json:"variants" dynamodbav:"Variants"`
+}
+
+// ImageSet は表紙画像のサイズ・形式違いの一覧です。Sourcesは優先する形式(WebPなど)から順に並びます。
+type ImageSet struct {
+	// Src はsrcsetに対応しないクライアント向けの画像です。
+	Src string `json:"src" dynamodbav:"Src"`
+	// Width と Height は元画像の大きさです。表示前に縦横比を確保するために使用します。
+	Width   int           `json:"width" dynamodbav:"Width"`
+	Height  int           `json:"height" dynamodbav:"Height"`
+	Sources []ImageSource `json:"sources" dynamodbav:"Sources"`
+}
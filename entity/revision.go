@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// Revision は漫画の要約を書き換えたときに保存する、変更後の内容のスナップショットです。保存後は変更しません。
+type Revision struct {
+	ComicID   int       `json:"comic_id" dynamodbav:"ComicID"`
+	Revision  int       `json:"revision" dynamodbav:"Revision"`
+	Comic     Comic     `json:"comic" dynamodbav:"Comic"`
+	Author    string    `json:"author" dynamodbav:"Author"`
+	Reason    string    `json:"reason" dynamodbav:"Reason"`
+	CreatedAt time.Time `json:"created_at" dynamodbav:"CreatedAt"`
+}
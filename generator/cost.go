@@ -0,0 +1,238 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// ErrBudgetExceeded は次のリクエストで予算を超える可能性があるため生成を止めたことを表します。
+var ErrBudgetExceeded = errors.New("generation budget exceeded")
+
+// Price は100万トークンあたりの料金(USD)です。
+type Price struct {
+	PromptPerMillion     float64 `json:"prompt_per_million"`
+	CompletionPerMillion float64 `json:"completion_per_million"`
+}
+
+// PriceTable はモデル名ごとの料金表です。
+type PriceTable map[string]Price
+
+// DefaultPrices は既定の料金表です。実際の料金は -prices で指定したファイルで上書きしてください。
+var DefaultPrices = PriceTable{
+	"gpt-4o":        {PromptPerMillion: 5, CompletionPerMillion: 15},
+	"gpt-4o-mini":   {PromptPerMillion: 0.15, CompletionPerMillion: 0.6},
+	"gpt-4-turbo":   {PromptPerMillion: 10, CompletionPerMillion: 30},
+	"gpt-3.5-turbo": {PromptPerMillion: 0.5, CompletionPerMillion: 1.5},
+}
+
+// LoadPriceTable はモデル名をキーにしたJSONファイルから料金表を読み込みます。
+func LoadPriceTable(filename string) (PriceTable, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var table PriceTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return table, nil
+}
+
+// Lookup はモデルの料金を返します。"gpt-4o-2024-05-13" のような日付付きのモデル名は最も長く一致するモデルの料金を使います。
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+// Cost はトークン数から料金を見積もります。料金表にないモデルはfalseを返します。
+func (t PriceTable) Cost(usage Usage) (float64, bool) {
+	price, ok := t.Lookup(usage.Model)
+	if !ok {
+		return 0, false
+	}
+	return float64(usage.PromptTokens)/1e6*price.PromptPerMillion +
+		float64(usage.CompletionTokens)/1e6*price.CompletionPerMillion, true
+}
+
+// UsageEntry はタイトルごとの使用量です。
+type UsageEntry struct {
+	Title string
+	Usage Usage
+	Cost  float64
+	// Priced は料金表にモデルがあり、Costが見積もれたことを表します。
+	Priced bool
+}
+
+// Accountant は生成ジョブ全体のトークン使用量と料金を集計し、予算を超えないよう生成を止めます。
+type Accountant struct {
+	prices PriceTable
+	budget float64
+	// reserve は1タイトルの生成(再依頼を含む)で消費しうる最大のトークン数です。予算の判定に使用します。
+	reserve Usage
+
+	mu       sync.Mutex
+	entries  []UsageEntry
+	index    map[string]int
+	spent    float64
+	reserved float64
+	exceeded bool
+}
+
+// NewAccountant はAccountantを生成します。budgetが0以下の場合は予算を設けません。
+// model、promptTokens、maxAttemptsは、予算の判定で1タイトルあたりの最大の料金を見積もるために使用します。
+// 予算を設けるのにmodelが料金表にない場合は、予算を守れないためエラーを返します。
+func NewAccountant(prices PriceTable, budget float64, model string, promptTokens int, maxAttempts int) (*Accountant, error) {
+	if budget > 0 {
+		if _, ok := prices.Lookup(model); !ok {
+			return nil, fmt.Errorf("%s has no price in the price table; a budget cannot be enforced without it", model)
+		}
+	}
+	return &Accountant{
+		prices:  prices,
+		budget:  budget,
+		index:   make(map[string]int),
+		reserve: reserveUsage(model, promptTokens, maxAttempts),
+	}, nil
+}
+
+// reserveUsage はmaxAttempts回まで再依頼した場合に消費しうる最大のトークン数を見積もります。
+// 再依頼では前回までの出力を会話に含めて送り直すため、試行ごとにプロンプトが最大maxTokensずつ長くなります。
+func reserveUsage(model string, promptTokens int, maxAttempts int) Usage {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	usage := Usage{Model: model}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		usage.PromptTokens += promptTokens + attempt*maxTokens
+		usage.CompletionTokens += maxTokens
+	}
+	return usage
+}
+
+// Reserve は1タイトル分(再依頼を含む)の最大の料金を確保します。確保すると予算を超える場合はErrBudgetExceededを返します。
+// 確保した料金は生成の完了後にreleaseを呼んで解放してください。
+func (a *Accountant) Reserve() (release func(), err error) {
+	cost, _ := a.prices.Cost(a.reserve)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.budget > 0 && a.spent+a.reserved+cost > a.budget {
+		a.exceeded = true
+		return nil, fmt.Errorf("%w: spent $%.4f of $%.4f", ErrBudgetExceeded, a.spent, a.budget)
+	}
+	a.reserved += cost
+
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.reserved -= cost
+	}, nil
+}
+
+// Add はタイトルの使用量を記録します。再試行などで同じタイトルを複数回記録した場合は合算します。
+func (a *Accountant) Add(title string, usage Usage) {
+	cost, priced := a.prices.Cost(usage)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.spent += cost
+	if i, ok := a.index[title]; ok {
+		entry := &a.entries[i]
+		entry.Usage.add(usage)
+		entry.Cost += cost
+		entry.Priced = entry.Priced && priced
+		return
+	}
+	a.index[title] = len(a.entries)
+	a.entries = append(a.entries, UsageEntry{Title: title, Usage: usage, Cost: cost, Priced: priced})
+}
+
+// Spent はこれまでに記録した料金の合計を返します。
+func (a *Accountant) Spent() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.spent
+}
+
+// Exceeded は予算を理由に生成を止めたことがあるかを返します。
+func (a *Accountant) Exceeded() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.exceeded
+}
+
+// Report はタイトルごとの使用量と、モデルごと・全体の合計を書き出します。
+func (a *Accountant) Report(w io.Writer) error {
+	a.mu.Lock()
+	entries := append([]UsageEntry(nil), a.entries...)
+	a.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TITLE\tMODEL\tPROMPT\tCOMPLETION\tCOST (USD)\t")
+
+	totals := make(map[string]*UsageEntry)
+	var total UsageEntry
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t\n", entry.Title, entry.Usage.Model,
+			entry.Usage.PromptTokens, entry.Usage.CompletionTokens, formatCost(entry.Cost, entry.Priced))
+
+		t, ok := totals[entry.Usage.Model]
+		if !ok {
+			t = &UsageEntry{Usage: Usage{Model: entry.Usage.Model}, Priced: true}
+			totals[entry.Usage.Model] = t
+		}
+		t.Usage.add(Usage{PromptTokens: entry.Usage.PromptTokens, CompletionTokens: entry.Usage.CompletionTokens})
+		t.Cost += entry.Cost
+		t.Priced = t.Priced && entry.Priced
+
+		total.Usage.add(Usage{PromptTokens: entry.Usage.PromptTokens, CompletionTokens: entry.Usage.CompletionTokens})
+		total.Cost += entry.Cost
+	}
+
+	models := make([]string, 0, len(totals))
+	for model := range totals {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	fmt.Fprintln(tw, "\t\t\t\t\t")
+	for _, model := range models {
+		t := totals[model]
+		fmt.Fprintf(tw, "TOTAL\t%s\t%d\t%d\t%s\t\n", model, t.Usage.PromptTokens, t.Usage.CompletionTokens, formatCost(t.Cost, t.Priced))
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d titles\t%d\t%d\t%s\t\n", len(entries), total.Usage.PromptTokens, total.Usage.CompletionTokens, formatCost(total.Cost, true))
+	if a.budget > 0 {
+		fmt.Fprintf(tw, "BUDGET\t\t\t\t%s\t\n", formatCost(a.budget, true))
+	}
+
+	return tw.Flush()
+}
+
+func formatCost(cost float64, priced bool) string {
+	if !priced {
+		return "n/a"
+	}
+	return fmt.Sprintf("$%.4f", cost)
+}
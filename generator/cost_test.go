@@ -0,0 +1,81 @@
+package generator
+
+import (
+	"errors"
+	"testing"
+)
+
+var testPrices = PriceTable{
+	"test-model": {PromptPerMillion: 1, CompletionPerMillion: 1},
+}
+
+func TestAccountantReservesEveryAttempt(t *testing.T) {
+	// 1試行目は1000+4000、2試行目は前回の出力を含めて5000+4000、3試行目は9000+4000トークン
+	const perTitle = 27000.0 / 1e6
+	tests := []struct {
+		name        string
+		maxAttempts int
+		budget      float64
+		wantOK      bool
+	}{
+		{"one attempt fits", 1, 5000.0 / 1e6, true},
+		{"three attempts do not fit the cost of one", 3, 5000.0 / 1e6, false},
+		{"three attempts fit", 3, perTitle, true},
+		{"zero uses the default attempts", 0, perTitle - 1e-9, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAccountant(testPrices, tt.budget, "test-model", 1000, tt.maxAttempts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			release, err := a.Reserve()
+			if tt.wantOK {
+				if err != nil {
+					t.Fatalf("Reserve: %v", err)
+				}
+				release()
+				return
+			}
+			if !errors.Is(err, ErrBudgetExceeded) || !a.Exceeded() {
+				t.Errorf("Reserve = %v, want ErrBudgetExceeded", err)
+			}
+		})
+	}
+}
+
+func TestAccountantReservationsAddUp(t *testing.T) {
+	a, err := NewAccountant(testPrices, 27000.0/1e6*2, "test-model", 1000, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := a.Reserve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Reserve(); err != nil {
+		t.Fatal(err)
+	}
+	// 同時に確保できるのは予算に収まる分だけ
+	if _, err := a.Reserve(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("third Reserve = %v, want ErrBudgetExceeded", err)
+	}
+	first()
+	if _, err := a.Reserve(); err != nil {
+		t.Errorf("Reserve after release: %v", err)
+	}
+}
+
+func TestNewAccountantRequiresPriceForBudget(t *testing.T) {
+	if _, err := NewAccountant(testPrices, 1, "unknown-model", 1000, 3); err == nil {
+		t.Error("a budget for a model without a price was accepted")
+	}
+	// 日付付きのモデル名は料金表の名前で見積もれる
+	if _, err := NewAccountant(testPrices, 1, "test-model-2024-05-13", 1000, 3); err != nil {
+		t.Errorf("dated model name: %v", err)
+	}
+	// 予算がなければ料金は不要
+	if _, err := NewAccountant(testPrices, 0, "unknown-model", 1000, 3); err != nil {
+		t.Errorf("no budget: %v", err)
+	}
+}
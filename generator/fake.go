@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultCannedFile はタイトルごとの応答がない場合に使用するファイル名です。
+const defaultCannedFile = "_default.json"
+
+// FakeGenerator はディスク上の応答を返す決定的なSummaryGeneratorです。APIを呼ばずに生成処理を確認するために使用します。
+// 応答は <dir>/<タイトル>.json から読み込み、なければ <dir>/_default.json を使用します。
+type FakeGenerator struct {
+	dir string
+
+	// Limits は検証に使う最小文字数です。
+	Limits Limits
+	// PromptVersion は生成結果に記録するプロンプトのバージョンです。
+	PromptVersion string
+}
+
+// NewFakeGenerator はdirから応答を読み込むFakeGeneratorを生成します。
+func NewFakeGenerator(dir string) *FakeGenerator {
+	return &FakeGenerator{dir: dir, Limits: DefaultLimits}
+}
+
+func (g *FakeGenerator) Generate(ctx context.Context, title string) (*Summary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(filepath.Join(g.dir, CannedFileName(title)))
+	if os.IsNotExist(err) {
+		content, err = os.ReadFile(filepath.Join(g.dir, defaultCannedFile))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no canned response for %q: %w", title, err)
+	}
+
+	summary, err := Decode(string(content))
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(summary, g.Limits); err != nil {
+		return nil, err
+	}
+	summary.Usage = Usage{Model: "fake"}
+	summary.PromptVersion = g.PromptVersion
+	return summary, nil
+}
+
+// cannedFileReplacer はファイル名に使えない文字を置き換えます。
+var cannedFileReplacer = strings.NewReplacer(
+	"/", "_", `\`, "_", ":", "_", "*", "_", "?", "_", `"`, "_", "<", "_", ">", "_", "|", "_",
+)
+
+// CannedFileName はタイトルに対応する応答ファイルの名前を返します。
+func CannedFileName(title string) string {
+	return cannedFileReplacer.Replace(strings.TrimSpace(title)) + ".json"
+}
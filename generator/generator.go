@@ -0,0 +1,141 @@
+package generator
+
+import (
+	"comic-summaries/prompt"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Summary はLLMが漫画のタイトルから生成した要約です。JSONのキーはプロンプトで指定している形式に合わせています。
+type Summary struct {
+	Synopsis   string `json:"Synopsis"`
+	Genre      string `json:"Genre"`
+	Characters string `json:"Characters"`
+	Attraction string `json:"Attraction"`
+	Spoilers   string `json:"Spoilers"`
+
+	// Usage は生成に使用したモデルとトークン数です。LLMの出力には含まれません。
+	Usage Usage `json:"-"`
+	// PromptVersion は生成に使用したプロンプトのバージョンです。LLMの出力には含まれません。
+	PromptVersion string `json:"-"`
+}
+
+// Usage は生成に使用したモデルとトークン数です。再依頼した場合は全ての試行の合計になります。
+type Usage struct {
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// add はotherのトークン数を加算します。
+func (u *Usage) add(other Usage) {
+	if other.Model != "" {
+		u.Model = other.Model
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+}
+
+// UsageError は生成に失敗したものの、それまでにトークンを消費していたことを表します。
+type UsageError struct {
+	Usage Usage
+	Err   error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// UsageOf は生成結果またはエラーから消費したトークン数を取り出します。
+func UsageOf(summary *Summary, err error) Usage {
+	if summary != nil {
+		return summary.Usage
+	}
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return usageErr.Usage
+	}
+	return Usage{}
+}
+
+// SummaryGenerator は漫画のタイトルから要約を生成します。
+// 生成ツールや管理画面からの再生成はこのインターフェースにのみ依存し、プロバイダーを意識しません。
+type SummaryGenerator interface {
+	Generate(ctx context.Context, title string) (*Summary, error)
+}
+
+// Config はNewで生成するプロバイダーの設定です。
+type Config struct {
+	// Provider は "openai"、"local"、"fake" のいずれかです。
+	Provider string
+	// APIKey はOpenAIのAPIキーです。localではエンドポイントが要求する場合のみ使用します。
+	APIKey string
+	// Model は使用するモデル名です。空の場合はプロバイダーごとの既定値を使用します。
+	Model string
+	// Endpoint はlocalで使用するOpenAI互換APIのベースURLです。
+	Endpoint string
+	// CannedDir はfakeが応答を読み込むディレクトリです。
+	CannedDir string
+	// Prompt は使用するプロンプトです。
+	Prompt *prompt.Template
+	// Language は出力させる言語です。空の場合は日本語です。
+	Language string
+	// Limits は検証に使う最小文字数です。nilの場合はDefaultLimitsを使用します。
+	Limits *Limits
+	// MaxAttempts は検証に失敗した場合に再依頼を含めて試行する最大回数です。0の場合はdefaultMaxAttemptsを使用します。
+	MaxAttempts int
+}
+
+// New は設定に応じたSummaryGeneratorを生成します。
+func New(cfg Config) (SummaryGenerator, error) {
+	limits := DefaultLimits
+	if cfg.Limits != nil {
+		limits = *cfg.Limits
+	}
+
+	switch cfg.Provider {
+	case "":
+		return nil, fmt.Errorf("provider is required (expected openai, local or fake)")
+	case "openai", "local":
+		if cfg.Prompt == nil {
+			return nil, fmt.Errorf("%s provider requires a prompt", cfg.Provider)
+		}
+		var g *ChatGenerator
+		if cfg.Provider == "local" {
+			if cfg.Endpoint == "" {
+				return nil, fmt.Errorf("local provider requires an endpoint")
+			}
+			g = NewLocalGenerator(cfg.Endpoint, cfg.APIKey, cfg.Model, cfg.Prompt)
+		} else {
+			if cfg.APIKey == "" {
+				return nil, fmt.Errorf("openai provider requires an API key")
+			}
+			g = NewOpenAIGenerator(cfg.APIKey, cfg.Model, cfg.Prompt)
+		}
+		g.Limits = limits
+		if cfg.Language != "" {
+			g.Language = cfg.Language
+		}
+		if cfg.MaxAttempts > 0 {
+			g.MaxAttempts = cfg.MaxAttempts
+		}
+		return g, nil
+	case "fake":
+		if cfg.CannedDir == "" {
+			return nil, fmt.Errorf("fake provider requires a canned response directory")
+		}
+		g := NewFakeGenerator(cfg.CannedDir)
+		g.Limits = limits
+		if cfg.Prompt != nil {
+			g.PromptVersion = cfg.Prompt.Version
+		}
+		return g, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (expected openai, local or fake)", cfg.Provider)
+	}
+}
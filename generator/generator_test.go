@@ -0,0 +1,45 @@
+package generator
+
+import (
+	"comic-summaries/prompt"
+	"strings"
+	"testing"
+)
+
+func TestNewRejectsIncompleteConfig(t *testing.T) {
+	tmpl := &prompt.Template{}
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"empty provider", Config{Prompt: tmpl, APIKey: "key"}, "provider is required"},
+		{"unknown provider", Config{Provider: "other", Prompt: tmpl}, `unknown provider "other"`},
+		{"openai without a prompt", Config{Provider: "openai", APIKey: "key"}, "openai provider requires a prompt"},
+		{"openai without an API key", Config{Provider: "openai", Prompt: tmpl}, "requires an API key"},
+		{"local without an endpoint", Config{Provider: "local", Prompt: tmpl}, "requires an endpoint"},
+		{"fake without canned responses", Config{Provider: "fake"}, "requires a canned response directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAppliesOptions(t *testing.T) {
+	gen, err := New(Config{Provider: "openai", APIKey: "key", Prompt: &prompt.Template{}, Language: "English", MaxAttempts: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, ok := gen.(*ChatGenerator)
+	if !ok {
+		t.Fatalf("New returned %T, want *ChatGenerator", gen)
+	}
+	if g.Language != "English" || g.MaxAttempts != 5 || g.Limits != DefaultLimits {
+		t.Errorf("generator = %+v", g)
+	}
+}
@@ -0,0 +1,223 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	jobManifestFile = "job.json"
+	jobResultsFile  = "results.jsonl"
+)
+
+// 生成結果の状態です。
+const (
+	StatusDone   = "done"
+	StatusFailed = "failed"
+)
+
+// JobManifest は生成ジョブの設定を記録します。
+type JobManifest struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+}
+
+// JournalEntry はジャーナルに1行ずつ追記するタイトルごとの生成結果です。
+type JournalEntry struct {
+	Title   string   `json:"title"`
+	Status  string   `json:"status"`
+	Summary *Summary `json:"summary,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Usage   Usage    `json:"usage"`
+	// PromptVersion は生成に使用したプロンプトのバージョンです。
+	PromptVersion string    `json:"prompt_version,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// Journal は生成ジョブのマニフェストとタイトルごとの結果をディレクトリに保存します。
+// 途中で終了したジョブを再実行すると、生成済みのタイトルはAPIを呼ばずにジャーナルから復元されます。
+type Journal struct {
+	dir      string
+	manifest JobManifest
+
+	mu      sync.Mutex
+	file    *os.File
+	entries map[string]JournalEntry
+}
+
+// OpenJournal はdirのジョブを開きます。マニフェストがなければmanifestを書き込んで新しいジョブを作成し、
+// あれば既存のマニフェストと結果を読み込みます。
+func OpenJournal(dir string, manifest JobManifest) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	manifestPath := filepath.Join(dir, jobManifestFile)
+	data, err := os.ReadFile(manifestPath)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, os.ErrNotExist):
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(manifestPath, data, 0o644); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	j := &Journal{
+		dir:      dir,
+		manifest: manifest,
+		entries:  make(map[string]JournalEntry),
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+
+	j.file, err = os.OpenFile(filepath.Join(dir, jobResultsFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// load は結果ファイルを読み込みます。同じタイトルは後の行を優先し、書き込み途中で終わった最後の行は無視します。
+func (j *Journal) load() error {
+	file, err := os.Open(filepath.Join(j.dir, jobResultsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry JournalEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr == nil {
+				j.entries[entry.Title] = entry
+			} else if err == nil {
+				return jsonErr
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Manifest はジョブのマニフェストを返します。
+func (j *Journal) Manifest() JobManifest {
+	return j.manifest
+}
+
+// Dir はジョブのディレクトリを返します。
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// Completed はtitleが生成済みであれば、その要約を返します。
+// 生成後の処理に失敗したタイトル (Failで記録したもの) も、要約を生成し直さずに済むよう要約を返します。
+func (j *Journal) Completed(title string) (*Summary, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[title]
+	if !ok || entry.Summary == nil {
+		return nil, false
+	}
+	summary := *entry.Summary
+	summary.Usage = entry.Usage
+	summary.PromptVersion = entry.PromptVersion
+	return &summary, true
+}
+
+// Failed はこれまでに失敗したまま完了していないタイトルを返します。
+func (j *Journal) Failed() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var failed []JournalEntry
+	for _, entry := range j.entries {
+		if entry.Status == StatusFailed {
+			failed = append(failed, entry)
+		}
+	}
+	return failed
+}
+
+// Record は生成結果を追記し、すぐにディスクへ書き出します。
+func (j *Journal) Record(result Result) error {
+	entry := JournalEntry{
+		Title:   result.Title,
+		Status:  StatusDone,
+		Summary: result.Summary,
+		Usage:   result.Usage,
+		At:      time.Now().UTC(),
+	}
+	if result.Summary != nil {
+		entry.PromptVersion = result.Summary.PromptVersion
+	}
+	if result.Err != nil {
+		entry.Status = StatusFailed
+		entry.Summary = nil
+		entry.Error = result.Err.Error()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.append(entry)
+}
+
+// Fail は要約の生成後の処理 (画像の取得など) に失敗したタイトルを失敗として記録します。
+// 生成済みの要約は残すため、再実行では要約を生成し直さずに失敗した処理からやり直します。
+func (j *Journal) Fail(title string, err error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := j.entries[title]
+	entry.Title = title
+	entry.Status = StatusFailed
+	entry.Error = err.Error()
+	entry.At = time.Now().UTC()
+	return j.append(entry)
+}
+
+// append はentryを結果ファイルに追記し、すぐにディスクへ書き出します。j.muを保持して呼び出してください。
+func (j *Journal) append(entry JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.entries[entry.Title] = entry
+	return nil
+}
+
+// Close は結果ファイルを閉じます。
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
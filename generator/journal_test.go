@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJournalFailKeepsSummaryForRerun(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, JobManifest{ID: "job"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := &Summary{Synopsis: "あらすじ", PromptVersion: "v1"}
+	usage := Usage{Model: "gpt-4o", PromptTokens: 10, CompletionTokens: 20}
+	if err := journal.Record(Result{Title: "漫画", Summary: summary, Usage: usage}); err != nil {
+		t.Fatal(err)
+	}
+	// 生成後の画像の取得に失敗した
+	if err := journal.Fail("漫画", errors.New("download image: status code 404")); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 再実行では失敗として報告しつつ、要約は生成し直さずに使う
+	journal, err = OpenJournal(dir, JobManifest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	failed := journal.Failed()
+	if len(failed) != 1 || failed[0].Title != "漫画" || failed[0].Error != "download image: status code 404" {
+		t.Errorf("Failed = %+v", failed)
+	}
+	got, ok := journal.Completed("漫画")
+	if !ok || got.Synopsis != "あらすじ" || got.PromptVersion != "v1" || got.Usage != usage {
+		t.Errorf("Completed = %+v, %v, want the generated summary", got, ok)
+	}
+
+	// 処理し直して完了すれば失敗の一覧から外れる
+	if err := journal.Record(Result{Title: "漫画", Summary: got, Usage: got.Usage}); err != nil {
+		t.Fatal(err)
+	}
+	if failed := journal.Failed(); len(failed) != 0 {
+		t.Errorf("Failed after the rerun = %+v", failed)
+	}
+}
+
+func TestJournalGenerationFailureIsRetried(t *testing.T) {
+	journal, err := OpenJournal(t.TempDir(), JobManifest{ID: "job"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	if err := journal.Record(Result{Title: "漫画", Err: errors.New("invalid summary")}); err != nil {
+		t.Fatal(err)
+	}
+	// 生成に失敗したタイトルは要約がないため生成し直す
+	if _, ok := journal.Completed("漫画"); ok {
+		t.Error("Completed returned a title whose generation failed")
+	}
+}
@@ -0,0 +1,22 @@
+package generator
+
+import (
+	"comic-summaries/prompt"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// NewLocalGenerator はOpenAI互換のHTTPエンドポイント(llama.cpp、Ollama、vLLMなど)を使うChatGeneratorを生成します。
+// endpointには "http://localhost:11434/v1" のように /chat/completions を除いたベースURLを指定します。
+func NewLocalGenerator(endpoint string, apiKey string, model string, tmpl *prompt.Template) *ChatGenerator {
+	cfg := openai.DefaultConfig(apiKey)
+	cfg.BaseURL = endpoint
+
+	return &ChatGenerator{
+		client:      openai.NewClientWithConfig(cfg),
+		model:       model,
+		prompt:      tmpl,
+		Limits:      DefaultLimits,
+		MaxAttempts: defaultMaxAttempts,
+		Language:    defaultLanguage,
+	}
+}
@@ -0,0 +1,162 @@
+package generator
+
+import (
+	"comic-summaries/prompt"
+	"context"
+	"errors"
+	"fmt"
+	openai "github.com/sashabaranov/go-openai"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTokens は1回の生成で許可する最大トークン数です。
+const maxTokens = 4000
+
+// defaultLanguage は出力させる既定の言語です。
+const defaultLanguage = "日本語"
+
+// defaultMaxAttempts は検証に失敗した場合に再依頼を含めて試行する既定の回数です。
+const defaultMaxAttempts = 3
+
+// ChatGenerator はChat Completions APIを使って要約を生成します。
+// 出力が検証に通らない場合は、理由を伝えて同じ会話の中で出力し直すよう依頼します。
+type ChatGenerator struct {
+	client *openai.Client
+	model  string
+	prompt *prompt.Template
+
+	// Limits は検証に使う最小文字数です。
+	Limits Limits
+	// MaxAttempts は再依頼を含めて試行する最大回数です。
+	MaxAttempts int
+	// Language は出力させる言語です。
+	Language string
+}
+
+// NewOpenAIGenerator はOpenAIのAPIを使うChatGeneratorを生成します。modelが空の場合はGPT-4oを使用します。
+func NewOpenAIGenerator(apiKey string, model string, tmpl *prompt.Template) *ChatGenerator {
+	if model == "" {
+		model = openai.GPT4o
+	}
+	return &ChatGenerator{
+		client:      openai.NewClient(apiKey),
+		model:       model,
+		prompt:      tmpl,
+		Limits:      DefaultLimits,
+		MaxAttempts: defaultMaxAttempts,
+		Language:    defaultLanguage,
+	}
+}
+
+func (g *ChatGenerator) Generate(ctx context.Context, title string) (*Summary, error) {
+	system, user, err := g.prompt.Render(prompt.Data{
+		Title:         title,
+		Language:      g.Language,
+		SynopsisMin:   g.Limits.Synopsis,
+		AttractionMin: g.Limits.Attraction,
+		SpoilersMin:   g.Limits.Spoilers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: system,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: user,
+		},
+	}
+
+	usage := Usage{Model: g.model}
+	var lastErr error
+	for attempt := 1; attempt <= g.MaxAttempts; attempt++ {
+		req := openai.ChatCompletionRequest{
+			Model:     g.model,
+			Messages:  messages,
+			MaxTokens: maxTokens,
+			// ResponseFormatにJSONを指定する
+			ResponseFormat: &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+			},
+		}
+
+		if err := beforeRequest(ctx, estimatePromptTokens(messages)); err != nil {
+			return nil, &UsageError{Usage: usage, Err: err}
+		}
+		resp, err := g.client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return nil, &UsageError{Usage: usage, Err: err}
+		}
+		usage.add(Usage{
+			Model:            resp.Model,
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		})
+		if len(resp.Choices) == 0 {
+			return nil, &UsageError{Usage: usage, Err: fmt.Errorf("%s returned no choices", g.model)}
+		}
+
+		choice := resp.Choices[0]
+		summary, err := Decode(choice.Message.Content)
+		if err == nil {
+			err = Validate(summary, g.Limits)
+		}
+		if err == nil {
+			summary.Usage = usage
+			summary.PromptVersion = g.prompt.Version
+			return summary, nil
+		}
+		lastErr = err
+
+		// 指摘を伝えて出力し直してもらう
+		messages = append(messages,
+			choice.Message,
+			openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleUser,
+				Content: reaskMessage(err, choice.FinishReason == openai.FinishReasonLength),
+			},
+		)
+	}
+
+	return nil, &UsageError{
+		Usage: usage,
+		Err:   fmt.Errorf("%s: gave up after %d attempts: %w", title, g.MaxAttempts, lastErr),
+	}
+}
+
+// estimatePromptTokens はレート制限のためにプロンプトのトークン数を見積もります。
+// トークナイザーを使わず、日本語で1文字1トークン程度になることから文字数で多めに見積もります。
+func estimatePromptTokens(messages []openai.ChatCompletionMessage) int {
+	tokens := 0
+	for _, message := range messages {
+		// 役割などの区切りの分を加える
+		tokens += utf8.RuneCountInString(message.Content) + 4
+	}
+	return tokens
+}
+
+// reaskMessage は検証に失敗した出力に対する再依頼のメッセージを組み立てます。
+func reaskMessage(err error, truncated bool) string {
+	var b strings.Builder
+	b.WriteString("先ほどの出力には以下の問題がありました。\n")
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		for _, problem := range validationErr.Problems {
+			b.WriteString("・" + problem + "\n")
+		}
+	} else {
+		b.WriteString("・JSONとして読み込めません: " + err.Error() + "\n")
+	}
+	if truncated {
+		b.WriteString("・出力がトークン上限で途中で切れています。各項目を簡潔にまとめてください。\n")
+	}
+
+	b.WriteString("問題を修正し、指定したJSON形式のみで全体を出力し直してください。")
+	return b.String()
+}
@@ -0,0 +1,306 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits は各項目の最小文字数です。プロンプトにも同じ値を埋め込んで指示します。
+type Limits struct {
+	Synopsis   int
+	Attraction int
+	Spoilers   int
+}
+
+// DefaultLimits は既定の最小文字数(あらすじ100文字、魅力300文字、ネタバレ1000文字)です。
+var DefaultLimits = Limits{
+	Synopsis:   100,
+	Attraction: 300,
+	Spoilers:   1000,
+}
+
+// ValidationError は生成された要約が検証に通らなかった理由の一覧です。
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid summary: " + strings.Join(e.Problems, "; ")
+}
+
+// Decode はLLMの出力を厳密にSummaryへデコードします。
+// そのままでは読めない場合はRepairで修復してから再度デコードし、それでも読めなければエラーを返します。
+func Decode(content string) (*Summary, error) {
+	summary, err := decodeStrict(content)
+	if err == nil {
+		return summary, nil
+	}
+
+	repaired, repairErr := decodeStrict(Repair(content))
+	if repairErr != nil {
+		return nil, fmt.Errorf("malformed JSON: %w", err)
+	}
+	return repaired, nil
+}
+
+// decodeStrict は未知のキーや文字列以外の値、JSONの後ろに続くデータをエラーとしてデコードします。
+func decodeStrict(content string) (*Summary, error) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	summary := &Summary{}
+	if err := decoder.Decode(summary); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON object")
+	}
+	return summary, nil
+}
+
+// Repair はLLMの出力によく見られる不備を修復します。
+// コードフェンスやJSONの前後の文章を取り除き、末尾のカンマを削除し、途中で切れた文字列や括弧を閉じます。
+func Repair(content string) string {
+	content = strings.TrimSpace(content)
+
+	// ```json ... ``` のようなコードフェンスを取り除く
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```")
+		if i := strings.IndexByte(content, '\n'); i >= 0 {
+			content = content[i+1:]
+		} else {
+			content = strings.TrimPrefix(content, "json")
+		}
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	}
+
+	// JSONオブジェクトの前後にある説明文を取り除く。閉じ括弧がない場合は途中で切れたものとして末尾まで残す
+	if start := strings.IndexByte(content, '{'); start > 0 {
+		content = content[start:]
+	}
+	if end := strings.LastIndexByte(content, '}'); end >= 0 && balanced(content[:end+1]) {
+		content = content[:end+1]
+	}
+
+	return closeTruncated(removeTrailingCommas(content))
+}
+
+// removeTrailingCommas は文字列の外にある "}" や "]" の直前のカンマを削除します。
+func removeTrailingCommas(content string) string {
+	var out bytes.Buffer
+	inString, escaped := false, false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			out.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		if c == ',' {
+			j := i + 1
+			for j < len(content) && isSpace(content[j]) {
+				j++
+			}
+			if j == len(content) || content[j] == '}' || content[j] == ']' {
+				continue
+			}
+		}
+		if c == '"' {
+			inString = true
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
+
+// closeTruncated は途中で切れた出力の文字列と括弧を閉じます。値のないキーで切れている場合はそのキーを捨てます。
+func closeTruncated(content string) string {
+	var stack []byte
+	inString, escaped := false, false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if len(stack) == 0 && !inString {
+		return content
+	}
+
+	if inString {
+		if escaped {
+			content = content[:len(content)-1]
+		}
+		// マルチバイト文字の途中で切れている場合は不完全なバイトを捨てる
+		for len(content) > 0 && !utf8.ValidString(content) {
+			content = content[:len(content)-1]
+		}
+		content += `"`
+	}
+
+	trimmed := strings.TrimRightFunc(content, func(r rune) bool { return r < utf8.RuneSelf && isSpace(byte(r)) })
+	switch {
+	case strings.HasSuffix(trimmed, ","):
+		content = strings.TrimSuffix(trimmed, ",")
+	case strings.HasSuffix(trimmed, ":"):
+		// "Spoilers": で切れている場合はキーごと捨てる
+		content = dropDanglingKey(strings.TrimSuffix(trimmed, ":"))
+	}
+	if strings.HasSuffix(content, `"`) && len(stack) > 0 && stack[len(stack)-1] == '}' && endsWithKey(content) {
+		content = dropDanglingKey(content)
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		content += string(stack[i])
+	}
+	return content
+}
+
+// endsWithKey は末尾の文字列がオブジェクトのキー(直前が "{" か ",")であるかを判定します。
+func endsWithKey(content string) bool {
+	start := lastStringStart(content)
+	if start < 0 {
+		return false
+	}
+	before := strings.TrimRight(content[:start], " \t\r\n")
+	return strings.HasSuffix(before, "{") || strings.HasSuffix(before, ",")
+}
+
+// dropDanglingKey は末尾の値のないキーと直前のカンマを取り除きます。
+func dropDanglingKey(content string) string {
+	content = strings.TrimRight(content, " \t\r\n")
+	start := lastStringStart(content)
+	if start < 0 {
+		return content
+	}
+	content = strings.TrimRight(content[:start], " \t\r\n")
+	return strings.TrimSuffix(content, ",")
+}
+
+// lastStringStart は末尾が文字列で終わる場合に、その開始位置の引用符のインデックスを返します。
+func lastStringStart(content string) int {
+	if !strings.HasSuffix(content, `"`) {
+		return -1
+	}
+	for i := len(content) - 2; i >= 0; i-- {
+		if content[i] != '"' {
+			continue
+		}
+		backslashes := 0
+		for j := i - 1; j >= 0 && content[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// balanced は文字列の外にある括弧の対応が取れているかを判定します。
+func balanced(content string) bool {
+	depth := 0
+	inString, escaped := false, false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		}
+	}
+	return depth == 0 && !inString
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// Validate は必須項目が揃っていることと、各項目がlimitsの文字数を満たしていることを検証します。
+func Validate(summary *Summary, limits Limits) error {
+	var problems []string
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"Synopsis", summary.Synopsis},
+		{"Genre", summary.Genre},
+		{"Characters", summary.Characters},
+		{"Attraction", summary.Attraction},
+		{"Spoilers", summary.Spoilers},
+	}
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			problems = append(problems, field.name+" is missing")
+		}
+	}
+
+	lengths := []struct {
+		name  string
+		value string
+		min   int
+	}{
+		{"Synopsis", summary.Synopsis, limits.Synopsis},
+		{"Attraction", summary.Attraction, limits.Attraction},
+		{"Spoilers", summary.Spoilers, limits.Spoilers},
+	}
+	for _, field := range lengths {
+		if field.value == "" {
+			continue
+		}
+		if n := utf8.RuneCountInString(field.value); n < field.min {
+			problems = append(problems, fmt.Sprintf("%s has %d characters, needs at least %d", field.name, n, field.min))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
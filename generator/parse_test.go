@@ -0,0 +1,70 @@
+package generator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"complete JSON is unchanged", `{"Synopsis": "あらすじ"}`, `{"Synopsis": "あらすじ"}`},
+		{"code fence and prose", "以下が要約です。\n```json\n{\"Synopsis\": \"a\"}\n```\n以上です。", `{"Synopsis": "a"}`},
+		{"trailing commas", `{"Tags": ["a", "b",], "Synopsis": "a",}`, `{"Tags": ["a", "b"], "Synopsis": "a"}`},
+
+		// 文字列の途中で切れた場合
+		{"inside a string", `{"Synopsis": "あらす`, `{"Synopsis": "あらす"}`},
+		{"inside a multibyte character", "{\"Synopsis\": \"あら\xe3\x81", `{"Synopsis": "あら"}`},
+		{"brackets inside a string are not counted", `{"Synopsis": "}{][", "Genre": "少年`, `{"Synopsis": "}{][", "Genre": "少年"}`},
+		{"inside a key", `{"Synopsis": "a", "Gen`, `{"Synopsis": "a"}`},
+
+		// エスケープシーケンスの途中で切れた場合
+		{"after a backslash", `{"Synopsis": "a\`, `{"Synopsis": "a"}`},
+		{"after an escaped quote", `{"Synopsis": "a \"b`, `{"Synopsis": "a \"b"}`},
+		{"after an escaped backslash", `{"Synopsis": "a\\`, `{"Synopsis": "a\\"}`},
+
+		// 値のないキーで切れた場合
+		{"key without a colon", `{"Synopsis": "a", "Genre"`, `{"Synopsis": "a"}`},
+		{"key with a colon", `{"Synopsis": "a", "Genre": `, `{"Synopsis": "a"}`},
+		{"after a comma", `{"Synopsis": "a", `, `{"Synopsis": "a"}`},
+
+		// 入れ子の配列やオブジェクトの途中で切れた場合
+		{"inside an array", `{"Tags": ["a", "b`, `{"Tags": ["a", "b"]}`},
+		{"after a comma in an array", `{"Tags": ["a", "b",`, `{"Tags": ["a", "b"]}`},
+		{"inside nested objects and arrays", `{"a": {"b": [1, {"c": "d`, `{"a": {"b": [1, {"c": "d"}]}}`},
+		{"key inside a nested object", `{"a": [{"b": "c", "d": `, `{"a": [{"b": "c"}]}`},
+		{"after a closed nested object", `{"a": {"b": "c"}, "d": ["e"]`, `{"a": {"b": "c"}, "d": ["e"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Repair(tt.content)
+			if got != tt.want {
+				t.Errorf("Repair(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("Repair(%q) = %q is not valid JSON", tt.content, got)
+			}
+		})
+	}
+}
+
+func TestDecodeRepairsTruncatedSummary(t *testing.T) {
+	summary, err := Decode("```json\n{\"Synopsis\": \"あらすじ\", \"Genre\": \"少年漫画\", \"Spoilers\": \"結末は")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Synopsis != "あらすじ" || summary.Genre != "少年漫画" || summary.Spoilers != "結末は" {
+		t.Errorf("Decode = %+v", summary)
+	}
+
+	// 修復しても読めない場合は元のエラーを返す
+	if _, err := Decode(`{"Synopsis": 1}`); err == nil {
+		t.Error("Decode of a non-string value returned nil")
+	}
+	if _, err := Decode(`{"Unknown": "a"}`); err == nil {
+		t.Error("Decode of an unknown key returned nil")
+	}
+}
@@ -0,0 +1,255 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	openai "github.com/sashabaranov/go-openai"
+	"golang.org/x/time/rate"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PoolOptions はPoolの並列数・レート制限・リトライの設定です。0の項目は既定値または無制限になります。
+type PoolOptions struct {
+	// Concurrency は同時に生成するタイトル数です。既定値は1です。
+	Concurrency int
+	// RequestsPerMinute は1分あたりのリクエスト数の上限です。0の場合は制限しません。
+	RequestsPerMinute int
+	// TokensPerMinute は1分あたりのトークン数の上限です。0の場合は制限しません。
+	TokensPerMinute int
+	// TokensPerRequest は1リクエストで出力すると見込むトークン数です。レート制限ではプロンプトの見積もりに加えて数えます。既定値はmaxTokensです。
+	TokensPerRequest int
+	// MaxRetries は429や5xxで失敗した場合に再試行する回数です。
+	MaxRetries int
+	// BaseBackoff は最初の再試行までの待ち時間です。再試行のたびに倍になります。既定値は2秒です。
+	BaseBackoff time.Duration
+	// MaxBackoff は再試行までの待ち時間の上限です。既定値は1分です。
+	MaxBackoff time.Duration
+	// Journal が指定されていれば、生成済みのタイトルを読み飛ばし、結果を1件ごとに記録します。
+	Journal *Journal
+	// Accountant が指定されていれば、タイトルごとの使用量を集計し、予算を超える前に生成を止めます。
+	Accountant *Accountant
+}
+
+// Result は1タイトル分の生成結果です。
+type Result struct {
+	Index   int
+	Title   string
+	Summary *Summary
+	Err     error
+	// Usage は今回の実行で消費したトークン数です。ジャーナルから復元した結果では0になります。
+	Usage Usage
+	// Resumed はジャーナルに記録済みの結果を再利用したことを表します。
+	Resumed bool
+}
+
+// Pool は複数のタイトルの要約をワーカーで並列に生成します。
+type Pool struct {
+	gen          SummaryGenerator
+	opts         PoolOptions
+	requestLimit *rate.Limiter
+	tokenLimit   *rate.Limiter
+}
+
+// NewPool はgenを使って生成するPoolを作成します。
+func NewPool(gen SummaryGenerator, opts PoolOptions) *Pool {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.TokensPerRequest <= 0 {
+		opts.TokensPerRequest = maxTokens
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 2 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Minute
+	}
+
+	p := &Pool{gen: gen, opts: opts}
+	if opts.RequestsPerMinute > 0 {
+		p.requestLimit = rate.NewLimiter(rate.Limit(float64(opts.RequestsPerMinute)/60), 1)
+	}
+	if opts.TokensPerMinute > 0 {
+		p.tokenLimit = rate.NewLimiter(rate.Limit(float64(opts.TokensPerMinute)/60), opts.TokensPerMinute)
+		if p.opts.TokensPerRequest > opts.TokensPerMinute {
+			p.opts.TokensPerRequest = opts.TokensPerMinute
+		}
+	}
+	return p
+}
+
+// Run はtitlesの要約を生成し、完了順によらずtitlesと同じ順序で結果を返します。
+// ctxがキャンセルされると未着手のタイトルはctxのエラーで終わります。
+func (p *Pool) Run(ctx context.Context, titles []string) []Result {
+	results := make([]Result, len(titles))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < p.opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				summary, usage, err := p.generate(ctx, titles[i])
+				results[i] = Result{Index: i, Title: titles[i], Summary: summary, Err: err, Usage: usage}
+				// 中断や予算超過による失敗は記録せず、再実行時にそのまま再試行する
+				interrupted := ctx.Err() != nil || errors.Is(err, ErrBudgetExceeded)
+				if p.opts.Journal != nil && (err == nil || !interrupted) {
+					if err := p.opts.Journal.Record(results[i]); err != nil {
+						results[i].Err = fmt.Errorf("record %s in journal: %w", titles[i], err)
+					}
+				}
+			}
+		}()
+	}
+
+	for i := range titles {
+		if p.opts.Journal != nil {
+			if summary, ok := p.opts.Journal.Completed(titles[i]); ok {
+				results[i] = Result{Index: i, Title: titles[i], Summary: summary, Resumed: true}
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			results[i] = Result{Index: i, Title: titles[i], Err: ctx.Err()}
+			continue
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			results[i] = Result{Index: i, Title: titles[i], Err: ctx.Err()}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// Generate は1タイトルをRunと同じくレート制限・再試行・予算を守って生成します。ジャーナルには記録しません。
+// PoolをSummaryGeneratorとして、1件ずつ生成する再生成ツールなどで使えます。
+func (p *Pool) Generate(ctx context.Context, title string) (*Summary, error) {
+	summary, usage, err := p.generate(ctx, title)
+	if err != nil {
+		return nil, &UsageError{Usage: usage, Err: err}
+	}
+	return summary, nil
+}
+
+// generate はレート制限を守りながら1タイトルを生成し、一時的なエラーであれば間隔を空けて再試行します。
+// 返すUsageは再試行を含めて消費したトークン数の合計です。
+func (p *Pool) generate(ctx context.Context, title string) (*Summary, Usage, error) {
+	// 再依頼を含め、APIを呼び出すたびにレート制限を守る
+	ctx = withRequestHook(ctx, p.wait)
+	var total Usage
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, total, err
+		}
+
+		summary, err := p.generateOnce(ctx, title)
+		total.add(UsageOf(summary, err))
+		if err == nil {
+			summary.Usage = total
+			return summary, total, nil
+		}
+		if attempt >= p.opts.MaxRetries || !IsRetryable(err) {
+			return nil, total, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, total, ctx.Err()
+		case <-time.After(p.backoff(attempt)):
+		}
+	}
+}
+
+// generateOnce は予算を確保してから1回生成し、使用量を記録します。
+func (p *Pool) generateOnce(ctx context.Context, title string) (*Summary, error) {
+	if p.opts.Accountant == nil {
+		return p.gen.Generate(ctx, title)
+	}
+
+	release, err := p.opts.Accountant.Reserve()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	summary, err := p.gen.Generate(ctx, title)
+	if usage := UsageOf(summary, err); usage != (Usage{}) {
+		p.opts.Accountant.Add(title, usage)
+	}
+	return summary, err
+}
+
+// wait はプロンプトがpromptTokensトークンのリクエストを送れるまで、レート制限の枠が空くのを待ちます。
+// ctxがキャンセルされていれば制限がなくても送りません。
+func (p *Pool) wait(ctx context.Context, promptTokens int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if p.requestLimit != nil {
+		if err := p.requestLimit.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if p.tokenLimit != nil {
+		// 1分の上限を超える見積もりは上限まで待てば送れるものとする
+		tokens := promptTokens + p.opts.TokensPerRequest
+		if tokens > p.tokenLimit.Burst() {
+			tokens = p.tokenLimit.Burst()
+		}
+		if err := p.tokenLimit.WaitN(ctx, tokens); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestHookKey はAPIを呼び出す前に呼ぶ関数をcontextに設定するキーです。
+type requestHookKey struct{}
+
+// withRequestHook はAPIを呼び出す直前にhookを呼ぶよう設定したcontextを返します。
+// 1回のGenerateで何度もAPIを呼び出すSummaryGeneratorでも、呼び出しごとにレート制限を守れるようにします。
+func withRequestHook(ctx context.Context, hook func(ctx context.Context, promptTokens int) error) context.Context {
+	return context.WithValue(ctx, requestHookKey{}, hook)
+}
+
+// beforeRequest はAPIを呼び出す直前に、ctxに設定された関数をプロンプトの見積もりトークン数で呼びます。
+func beforeRequest(ctx context.Context, promptTokens int) error {
+	if hook, ok := ctx.Value(requestHookKey{}).(func(ctx context.Context, promptTokens int) error); ok {
+		return hook(ctx, promptTokens)
+	}
+	return nil
+}
+
+// backoff は指数的に伸ばした待ち時間に揺らぎを加えて返します。
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := p.opts.BaseBackoff << attempt
+	if d <= 0 || d > p.opts.MaxBackoff {
+		d = p.opts.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// IsRetryable はレート制限(429)やサーバーエラー(5xx)のように、時間を置けば成功しうるエラーかを判定します。
+func IsRetryable(err error) bool {
+	var status int
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	default:
+		return false
+	}
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
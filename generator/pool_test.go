@@ -0,0 +1,293 @@
+package generator
+
+import (
+	"comic-summaries/prompt"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	openai "github.com/sashabaranov/go-openai"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestFakeGenerator は全てのタイトルに同じ応答を返すFakeGeneratorを生成します。
+func newTestFakeGenerator(t *testing.T) *FakeGenerator {
+	t.Helper()
+	dir := t.TempDir()
+	content, err := json.Marshal(Summary{
+		Synopsis:   "あらすじ",
+		Genre:      "少年漫画",
+		Characters: "主人公",
+		Attraction: "魅力",
+		Spoilers:   "ネタバレ",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, defaultCannedFile), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gen := NewFakeGenerator(dir)
+	gen.Limits = Limits{}
+	return gen
+}
+
+// scriptedGenerator はタイトルごとに決めたエラーを順に返し、尽きたらnextで生成します。
+type scriptedGenerator struct {
+	next  SummaryGenerator
+	delay func(title string) time.Duration
+
+	mu    sync.Mutex
+	errs  map[string][]error
+	calls map[string]int
+}
+
+func newScriptedGenerator(next SummaryGenerator) *scriptedGenerator {
+	return &scriptedGenerator{next: next, errs: map[string][]error{}, calls: map[string]int{}}
+}
+
+func (g *scriptedGenerator) Generate(ctx context.Context, title string) (*Summary, error) {
+	g.mu.Lock()
+	g.calls[title]++
+	var err error
+	if errs := g.errs[title]; len(errs) > 0 {
+		err, g.errs[title] = errs[0], errs[1:]
+	}
+	g.mu.Unlock()
+
+	if g.delay != nil {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(g.delay(title)):
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g.next.Generate(ctx, title)
+}
+
+func (g *scriptedGenerator) callCount(title string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.calls[title]
+}
+
+func apiError(status int) error {
+	return &openai.APIError{HTTPStatusCode: status, Message: http.StatusText(status)}
+}
+
+func TestPoolRunKeepsInputOrder(t *testing.T) {
+	gen := newScriptedGenerator(newTestFakeGenerator(t))
+	// 先のタイトルほど時間をかけ、完了順を入力と逆にする
+	var titles []string
+	delays := map[string]time.Duration{}
+	for i := 0; i < 20; i++ {
+		title := fmt.Sprintf("タイトル%02d", i)
+		titles = append(titles, title)
+		delays[title] = time.Duration(20-i) * time.Millisecond
+	}
+	gen.delay = func(title string) time.Duration { return delays[title] }
+
+	results := NewPool(gen, PoolOptions{Concurrency: 5}).Run(context.Background(), titles)
+
+	if len(results) != len(titles) {
+		t.Fatalf("got %d results, want %d", len(results), len(titles))
+	}
+	for i, result := range results {
+		if result.Index != i || result.Title != titles[i] {
+			t.Errorf("results[%d] = {Index: %d, Title: %s}, want {Index: %d, Title: %s}", i, result.Index, result.Title, i, titles[i])
+		}
+		if result.Err != nil || result.Summary == nil || result.Summary.Synopsis != "あらすじ" {
+			t.Errorf("results[%d] = %+v, want a summary", i, result)
+		}
+	}
+}
+
+func TestPoolRetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   int // 0の場合は成功する
+	}{
+		{"429 then success", []error{apiError(429), apiError(429)}, 3, 0},
+		{"5xx then success", []error{apiError(500), apiError(503)}, 3, 0},
+		{"wrapped in UsageError", []error{&UsageError{Usage: Usage{PromptTokens: 10}, Err: apiError(502)}}, 2, 0},
+		{"gives up after MaxRetries", []error{apiError(429), apiError(429), apiError(429), apiError(429)}, 4, 429},
+		{"400 is not retried", []error{apiError(400)}, 1, 400},
+		{"other errors are not retried", []error{errors.New("invalid JSON")}, 1, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := newScriptedGenerator(newTestFakeGenerator(t))
+			gen.errs["title"] = tt.errs
+			pool := NewPool(gen, PoolOptions{MaxRetries: 3, BaseBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+
+			result := pool.Run(context.Background(), []string{"title"})[0]
+
+			if got := gen.callCount("title"); got != tt.wantCalls {
+				t.Errorf("Generate was called %d times, want %d", got, tt.wantCalls)
+			}
+			switch {
+			case tt.wantErr == 0:
+				if result.Err != nil || result.Summary == nil {
+					t.Fatalf("result = %+v, want a summary", result)
+				}
+			case tt.wantErr > 0:
+				var apiErr *openai.APIError
+				if !errors.As(result.Err, &apiErr) || apiErr.HTTPStatusCode != tt.wantErr {
+					t.Errorf("err = %v, want status %d", result.Err, tt.wantErr)
+				}
+			default:
+				if result.Err == nil {
+					t.Error("err = nil, want an error")
+				}
+			}
+		})
+	}
+}
+
+func TestPoolSumsUsageAcrossRetries(t *testing.T) {
+	gen := newScriptedGenerator(newTestFakeGenerator(t))
+	gen.errs["title"] = []error{
+		&UsageError{Usage: Usage{Model: "m", PromptTokens: 10, CompletionTokens: 5}, Err: apiError(500)},
+		&UsageError{Usage: Usage{Model: "m", PromptTokens: 12, CompletionTokens: 0}, Err: apiError(429)},
+	}
+	pool := NewPool(gen, PoolOptions{MaxRetries: 2, BaseBackoff: time.Millisecond})
+
+	result := pool.Run(context.Background(), []string{"title"})[0]
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if result.Usage.PromptTokens != 22 || result.Usage.CompletionTokens != 5 {
+		t.Errorf("usage = %+v, want 22 prompt and 5 completion tokens", result.Usage)
+	}
+}
+
+func TestPoolRunStopsOnCancel(t *testing.T) {
+	gen := newScriptedGenerator(newTestFakeGenerator(t))
+	started := make(chan string, 10)
+	gen.delay = func(title string) time.Duration {
+		started <- title
+		return time.Hour
+	}
+	titles := []string{"a", "b", "c", "d", "e", "f"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan []Result)
+	go func() {
+		done <- NewPool(gen, PoolOptions{Concurrency: 2}).Run(ctx, titles)
+	}()
+	<-started
+	<-started
+	cancel()
+
+	var results []Result
+	select {
+	case results = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was canceled")
+	}
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("results[%d].Err = %v, want context.Canceled", i, result.Err)
+		}
+		if result.Title != titles[i] {
+			t.Errorf("results[%d].Title = %s, want %s", i, result.Title, titles[i])
+		}
+	}
+	// 未着手のタイトルは生成しない
+	for _, title := range titles[2:] {
+		if gen.callCount(title) != 0 {
+			t.Errorf("%s was generated after the cancel", title)
+		}
+	}
+}
+
+func TestPoolBackoffIsBounded(t *testing.T) {
+	pool := NewPool(nil, PoolOptions{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	for attempt := 0; attempt < 70; attempt++ {
+		d := pool.backoff(attempt)
+		if d <= 0 || d > time.Second {
+			t.Errorf("backoff(%d) = %s, want within (0, 1s]", attempt, d)
+		}
+	}
+}
+
+func TestPoolRateLimitsEveryReask(t *testing.T) {
+	// 検証に通らない出力を返し続け、ChatGeneratorに再依頼させる
+	var requests int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model:   "local",
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "{}"}}},
+		})
+	}))
+	defer server.Close()
+	tmpl, err := prompt.Load(prompt.Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := NewLocalGenerator(server.URL, "", "local", tmpl)
+
+	const tokensPerMinute, tokensPerRequest = 60000, 10000
+	pool := NewPool(gen, PoolOptions{TokensPerMinute: tokensPerMinute, TokensPerRequest: tokensPerRequest})
+	results := pool.Run(context.Background(), []string{"タイトル"})
+
+	if results[0].Err == nil {
+		t.Fatal("Run succeeded, want the invalid output to be rejected")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != defaultMaxAttempts {
+		t.Fatalf("server got %d requests, want %d", requests, defaultMaxAttempts)
+	}
+	// 再依頼のたびにプロンプトと出力の見込みを枠から引く (待っている間の回復分は1秒あたり1000トークン)
+	if got, max := pool.tokenLimit.Tokens(), float64(tokensPerMinute-defaultMaxAttempts*tokensPerRequest+5000); got > max {
+		t.Errorf("%.0f tokens left after %d requests, want at most %.0f", got, defaultMaxAttempts, max)
+	}
+}
+
+func TestPoolWaitCountsPromptTokens(t *testing.T) {
+	pool := NewPool(nil, PoolOptions{TokensPerMinute: 6000, TokensPerRequest: 1000})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := pool.wait(ctx, 4000); err != nil {
+		t.Fatalf("wait(4000) = %v, want nil", err)
+	}
+	// 残りの1000トークンではプロンプトの分が足りず、期限までに送れない
+	if err := pool.wait(ctx, 500); err == nil {
+		t.Error("wait(500) = nil, want an error because the prompt does not fit in the remaining tokens")
+	}
+}
+
+func TestPoolGenerateStopsAtBudget(t *testing.T) {
+	// 1タイトル分の最大の料金を確保できない予算では生成しない
+	accountant, err := NewAccountant(PriceTable{"fake": {PromptPerMillion: 1000, CompletionPerMillion: 1000}}, 0.01, "fake", 2000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := newScriptedGenerator(newTestFakeGenerator(t))
+	pool := NewPool(gen, PoolOptions{Accountant: accountant})
+
+	_, err = pool.Generate(context.Background(), "タイトル")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Generate = %v, want ErrBudgetExceeded", err)
+	}
+	if gen.callCount("タイトル") != 0 {
+		t.Error("the title was generated beyond the budget")
+	}
+}
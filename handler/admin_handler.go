@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"comic-summaries/controller"
+	"crypto/subtle"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// NewAdminHandler は管理者向けのルートを登録します。リクエストには Authorization: Bearer <token> が必要です。
+func NewAdminHandler(e *echo.Echo, ac controller.IAdminController, token string) {
+	g := e.Group("/admin", tokenAuth(token))
+
+	g.GET("/summaries/:id", ac.GetComic)
+	g.GET("/summaries", ac.GetAllComics)
+	g.PUT("/summaries/:id", ac.UpdateComic)
+
+	g.GET("/summaries/:id/revisions", ac.GetRevisions)
+	g.GET("/summaries/:id/revisions/diff", ac.DiffRevisions)
+	g.POST("/summaries/:id/revisions/:revision/rollback", ac.Rollback)
+
+	g.GET("/review", ac.GetReviewQueue)
+	g.POST("/summaries/:id/submit", ac.Submit)
+	g.POST("/summaries/:id/approve", ac.Approve)
+	g.POST("/summaries/:id/reject", ac.Reject)
+}
+
+// tokenAuth は Authorization: Bearer <token> のトークンがtokenと一致するリクエストだけを通します。
+func tokenAuth(token string) echo.MiddlewareFunc {
+	return middleware.KeyAuth(func(key string, c echo.Context) (bool, error) {
+		return subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+	})
+}
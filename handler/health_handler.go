@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"comic-summaries/controller"
+	"github.com/labstack/echo/v4"
+)
+
+// HealthPaths はオーケストレーターが頻繁に呼び出す確認のルートです。アクセスログから除きます。
+var HealthPaths = []string{"/healthz", "/readyz"}
+
+// NewHealthHandler は生存確認 (/healthz) と準備完了の確認 (/readyz) のルートを登録します。
+func NewHealthHandler(e *echo.Echo, hc controller.IHealthController) {
+	e.GET("/healthz", hc.Healthz)
+	e.GET("/readyz", hc.Readyz)
+}
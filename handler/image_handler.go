@@ -0,0 +1,11 @@
+package handler
+
+import (
+	"comic-summaries/controller"
+	"github.com/labstack/echo/v4"
+)
+
+// NewImageHandler は画像の配信ルートを登録します。画像はImageStoreから読み出します。
+func NewImageHandler(e *echo.Echo, ic controller.IImageController) {
+	e.GET("/images/*", ic.GetImage)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"comic-summaries/metrics"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewMetricsHandler はregistryのメトリクスをPrometheusのテキスト形式で返すルート (/metrics) を登録します。
+// 管理者向けのルートと同じく、リクエストには Authorization: Bearer <token> が必要です。
+func NewMetricsHandler(e *echo.Echo, registry *metrics.Registry, token string) {
+	e.GET("/metrics", echo.WrapHandler(registry.Handler()), tokenAuth(token))
+}
+
+// MetricsMiddleware はルートとステータスごとのリクエスト数と所要時間、処理中のリクエスト数を記録します。
+// ルートは /summaries/:id のような登録したパスで数え、どのルートにも当たらないリクエストは "unmatched" にまとめます。
+func MetricsMiddleware(registry *metrics.Registry) echo.MiddlewareFunc {
+	requests := registry.NewCounterVec("http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	duration := registry.NewHistogramVec("http_request_duration_seconds",
+		"Latency of HTTP requests by method, route and status.", nil, "method", "route", "status")
+	inFlight := registry.NewGaugeVec("http_requests_in_flight",
+		"HTTP requests currently being served.").With()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			inFlight.Inc()
+			defer inFlight.Dec()
+			start := time.Now()
+
+			// ステータスを確定させるため、エラーはここでレスポンスにする。
+			// 外側で二重にレスポンスを書かないよう、レスポンスにしたエラーは返さない
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			route := c.Path()
+			if route == "" || (route == "/*" && c.Response().Status == http.StatusNotFound) {
+				route = "unmatched"
+			}
+			status := strconv.Itoa(c.Response().Status)
+			requests.With(c.Request().Method, route, status).Inc()
+			duration.With(c.Request().Method, route, status).Observe(time.Since(start).Seconds())
+			return nil
+		}
+	}
+}
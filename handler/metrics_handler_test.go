@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"comic-summaries/metrics"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newMetricsServer(t *testing.T) (*echo.Echo, *int) {
+	t.Helper()
+	registry := metrics.NewRegistry()
+	e := echo.New()
+	handled := 0
+	defaultHandler := e.HTTPErrorHandler
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		handled++
+		defaultHandler(err, c)
+	}
+	e.Use(MetricsMiddleware(registry))
+	e.GET("/summaries/:id", func(c echo.Context) error {
+		if c.Param("id") == "0" {
+			return echo.NewHTTPError(http.StatusNotFound, "not found")
+		}
+		return c.String(http.StatusOK, "ok")
+	})
+	NewMetricsHandler(e, registry, "secret")
+	return e, &handled
+}
+
+func serveRequest(e *echo.Echo, path string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMetricsMiddlewareHandlesErrorsOnce(t *testing.T) {
+	e, handled := newMetricsServer(t)
+
+	rec := serveRequest(e, "/summaries/0", "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if *handled != 1 {
+		t.Errorf("the error handler ran %d times, want once", *handled)
+	}
+	if strings.Count(rec.Body.String(), "not found") != 1 {
+		t.Errorf("body = %q, want a single error response", rec.Body.String())
+	}
+
+	serveRequest(e, "/summaries/1", "")
+	serveRequest(e, "/nowhere", "")
+	body := serveRequest(e, "/metrics", "secret").Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/summaries/:id",status="404"} 1`,
+		`http_requests_total{method="GET",route="/summaries/:id",status="200"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %s:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsRequireToken(t *testing.T) {
+	e, _ := newMetricsServer(t)
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusBadRequest},
+		{"wrong token", "wrong", http.StatusUnauthorized},
+		{"admin token", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serveRequest(e, "/metrics", tt.token); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package imagecache
+
+import (
+	"comic-summaries/metrics"
+	"container/list"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache は縮小した画像をディスクに保存するキャッシュです。合計サイズが上限を超えると、最も長く使われていないものから削除します。
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	closed  bool
+	size    int64
+	order   *list.List // 先頭ほど最近使われたキー
+	entries map[string]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// Stats はキャッシュの利用状況です。HitsとMissesは起動してからの累計です。
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Size    int64
+}
+
+// ErrClosed はCloseした後のCacheに保存しようとしたことを表します。
+var ErrClosed = errors.New("image cache is closed")
+
+type entry struct {
+	key  string
+	size int64
+}
+
+// New はdirをキャッシュのディレクトリとするCacheを生成します。
+// dirに残っているファイルは更新日時の新しい順に読み込み、上限を超える分は削除します。
+func New(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type existing struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var found []existing
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, existing{key: file.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].modTime.After(found[j].modTime)
+	})
+	for _, f := range found {
+		c.entries[f.key] = c.order.PushBack(&entry{key: f.key, size: f.size})
+		c.size += f.size
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	return c, nil
+}
+
+// Get はキャッシュした画像を返します。ない場合はfalseを返します。
+// 読み込み中に削除や置き換えが起きても途中までの内容を返さないよう、ファイルはロックを保持したまま開きます。
+// 開いたファイルは削除されても読み終えるまで内容が残ります。
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	element, ok := c.entries[key]
+	var file *os.File
+	var err error
+	if ok {
+		c.order.MoveToFront(element)
+		file, err = os.Open(filepath.Join(c.dir, key))
+		if err != nil && errors.Is(err, fs.ErrNotExist) {
+			// 外部から削除された場合は一覧からも外す
+			c.removeLocked(key)
+		}
+	}
+	c.mu.Unlock()
+	if !ok || err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data, true
+}
+
+// Put は画像をキャッシュに保存します。上限より大きい画像は保存しません。
+// キーはファイル名として使うため、英数字などの安全な文字だけにしてください。
+func (c *Cache) Put(key string, data []byte) error {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return nil
+	}
+
+	// 書き込み途中のファイルを読まないよう、一時ファイルに書いてから置き換える
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, key)); err != nil {
+		return err
+	}
+	if element, ok := c.entries[key]; ok {
+		c.size -= element.Value.(*entry).size
+		c.order.Remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&entry{key: key, size: size})
+	c.size += size
+	c.evict()
+	return nil
+}
+
+// Close は以降の保存を止め、書き込み途中で残った一時ファイルを削除します。保存済みの画像は次の起動で読み込むため残します。
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	tmps, err := filepath.Glob(filepath.Join(c.dir, ".tmp-*"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, tmp := range tmps {
+		if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats はキャッシュの利用状況を返します。
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: len(c.entries),
+		Size:    c.size,
+	}
+}
+
+// RegisterMetrics はキャッシュの利用状況をregistryのメトリクスとして公開します。
+func (c *Cache) RegisterMetrics(registry *metrics.Registry) {
+	registry.NewCounterFunc("image_cache_hits_total", "Resized image cache hits.", func() float64 {
+		return float64(c.hits.Load())
+	})
+	registry.NewCounterFunc("image_cache_misses_total", "Resized image cache misses.", func() float64 {
+		return float64(c.misses.Load())
+	})
+	registry.NewGaugeFunc("image_cache_hit_ratio", "Ratio of resized image cache hits since start.", func() float64 {
+		hits, misses := c.hits.Load(), c.misses.Load()
+		if hits+misses == 0 {
+			return 0
+		}
+		return float64(hits) / float64(hits+misses)
+	})
+	registry.NewGaugeFunc("image_cache_entries", "Resized images in the cache.", func() float64 {
+		return float64(c.Stats().Entries)
+	})
+	registry.NewGaugeFunc("image_cache_size_bytes", "Total size of the resized images in the cache.", func() float64 {
+		return float64(c.Size())
+	})
+}
+
+// Size はキャッシュしている画像の合計バイト数を返します。
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// removeLocked はキーを一覧から外します。c.muを保持して呼び出してください。
+func (c *Cache) removeLocked(key string) {
+	if element, ok := c.entries[key]; ok {
+		c.size -= element.Value.(*entry).size
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// evict は合計サイズが上限以下になるまで最も長く使われていない画像を削除します。c.muを保持して呼び出してください。
+func (c *Cache) evict() {
+	for c.size > c.maxBytes {
+		element := c.order.Back()
+		if element == nil {
+			return
+		}
+		e := element.Value.(*entry)
+		os.Remove(filepath.Join(c.dir, e.key))
+		c.order.Remove(element)
+		delete(c.entries, e.key)
+		c.size -= e.size
+	}
+}
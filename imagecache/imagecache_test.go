@@ -0,0 +1,82 @@
+package imagecache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestGetReturnsWholeImageWhileReplacedAndEvicted(t *testing.T) {
+	c, err := New(t.TempDir(), 3<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := bytes.Repeat([]byte("a"), 1<<20)
+	second := bytes.Repeat([]byte("b"), 1<<20)
+	if err := c.Put("cover.jpg", first); err != nil {
+		t.Fatal(err)
+	}
+
+	// 読み込みの最中に同じキーを置き換えたり、他の画像で追い出したりする
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			data := first
+			if i%2 == 1 {
+				data = second
+			}
+			if err := c.Put("cover.jpg", data); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := c.Put("other.jpg", second); err != nil {
+				t.Error(err)
+				return
+			}
+			c.Put("large.jpg", bytes.Repeat([]byte("c"), 2<<20))
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		data, ok := c.Get("cover.jpg")
+		if ok && !bytes.Equal(data, first) && !bytes.Equal(data, second) {
+			t.Fatalf("Get returned a partial image of %d bytes", len(data))
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestGetForgetsDeletedFiles(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("cover.jpg", []byte("image")); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := c.Get("cover.jpg"); !ok || string(data) != "image" {
+		t.Fatalf("Get = %q, %v", data, ok)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "cover.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("cover.jpg"); ok {
+		t.Error("Get of a deleted file returned true")
+	}
+	if stats := c.Stats(); stats.Entries != 0 || stats.Size != 0 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats = %+v, want the deleted file to be forgotten", stats)
+	}
+}
@@ -0,0 +1,321 @@
+package imagecheck
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/imagepipeline"
+	"comic-summaries/imagestore"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency は同時に確認するURLの数の既定値です。
+const DefaultConcurrency = 8
+
+// DefaultTimeout は1つのURLの確認にかける時間の既定値です。
+const DefaultTimeout = 10 * time.Second
+
+// ErrNotStoreURL は保存先の画像でもHTTPのURLでもないため確認できないことを表します。
+var ErrNotStoreURL = errors.New("not an image store or HTTP URL")
+
+// Result は1つの画像のURLを確認した結果です。
+type Result struct {
+	URL string
+	// Key は保存先の画像のキーです。保存先の外のURLの場合は空です。
+	Key string
+	// Status はHTTPで確認した場合のステータスコードです。
+	Status int
+	Err    error
+}
+
+// OK は画像を取得できるかを返します。
+func (r Result) OK() bool {
+	return r.Err == nil && (r.Status == 0 || (r.Status >= 200 && r.Status < 300))
+}
+
+// String は確認結果を報告用の短い文字列にします。
+func (r Result) String() string {
+	switch {
+	case r.Err != nil:
+		return r.Err.Error()
+	case r.Status != 0:
+		return fmt.Sprintf("HTTP %d", r.Status)
+	default:
+		return "ok"
+	}
+}
+
+// Report は1つの漫画の画像を確認した結果です。
+type Report struct {
+	Comic   *entity.Comic
+	Results []Result
+}
+
+// Broken は取得できない画像があるかを返します。
+func (r *Report) Broken() bool {
+	for _, result := range r.Results {
+		if !result.OK() {
+			return true
+		}
+	}
+	return false
+}
+
+// Checker は漫画の画像のURLを確認します。保存先のURLは保存先に問い合わせ、それ以外のURLはHTTPで確認します。
+type Checker struct {
+	// Store は画像の保存先です。nilの場合は全てのURLをHTTPで確認します。
+	Store imagestore.ImageStore
+	// Client はHTTPで確認するクライアントです。既定値はhttp.DefaultClientです。
+	Client *http.Client
+	// Concurrency は同時に確認するURLの数です。既定値はDefaultConcurrencyです。
+	Concurrency int
+	// Timeout は1つのURLの確認にかける時間です。既定値はDefaultTimeoutです。
+	Timeout time.Duration
+}
+
+// Check は漫画の画像を全て確認します。複数の漫画が同じ画像を参照している場合は1回だけ確認します。
+// 結果はcomicsと同じ順に並びます。
+func (c *Checker) Check(ctx context.Context, comics []*entity.Comic) []Report {
+	var urls []string
+	seen := map[string]bool{}
+	for _, comic := range comics {
+		for _, u := range ImageURLs(comic) {
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+
+	results := c.CheckURLs(ctx, urls)
+	reports := make([]Report, len(comics))
+	for i, comic := range comics {
+		reports[i].Comic = comic
+		for _, u := range ImageURLs(comic) {
+			reports[i].Results = append(reports[i].Results, results[u])
+		}
+	}
+	return reports
+}
+
+// CheckURLs はURLを並行して確認し、URLごとの結果を返します。
+func (c *Checker) CheckURLs(ctx context.Context, urls []string) map[string]Result {
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	jobs := make(chan string)
+	results := make(map[string]Result, len(urls))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				result := c.CheckURL(ctx, u)
+				mu.Lock()
+				results[u] = result
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range urls {
+		jobs <- u
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// CheckURL は1つのURLの画像を取得できるかを確認します。
+func (c *Checker) CheckURL(ctx context.Context, imageURL string) Result {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := Result{URL: imageURL}
+	if c.Store != nil {
+		if key, ok := imagestore.KeyFromURL(c.Store, imageURL); ok {
+			result.Key = key
+			_, result.Err = c.Store.Stat(ctx, key)
+			return result
+		}
+	}
+
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		result.Err = ErrNotStoreURL
+		return result
+	}
+	result.Status, result.Err = c.fetchStatus(ctx, imageURL)
+	return result
+}
+
+// fetchStatus はHEADでステータスコードを確認します。HEADに対応しないサーバーには先頭の1バイトだけGETで要求します。
+func (c *Checker) fetchStatus(ctx context.Context, imageURL string) (int, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	status, err := c.do(ctx, client, http.MethodHead, imageURL)
+	if err != nil || (status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented) {
+		return status, err
+	}
+	return c.do(ctx, client, http.MethodGet, imageURL)
+}
+
+func (c *Checker) do(ctx context.Context, client *http.Client, method string, imageURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, imageURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10))
+	return resp.StatusCode, nil
+}
+
+// Repair は取得できない画像のURLを取得できるURLに置き換えます。reportはCheckの結果です。
+//   - 保存先に同じファイル名の画像があれば、そのURLに置き換えます (配信元やパスが変わった場合)。
+//   - ImagePathが取得できなければ、取得できる最も大きいJPEGの画像に置き換えます。
+//   - 取得できないサイズ・形式違いの画像は一覧から外します。
+//
+// 漫画を書き換えた場合はtrueを返します。置き換えられなかった画像はBrokenで確認できます。
+func (c *Checker) Repair(ctx context.Context, report *Report) bool {
+	comic := report.Comic
+	results := map[string]Result{}
+	for _, result := range report.Results {
+		results[result.URL] = result
+	}
+	changed := false
+
+	// 同じファイル名の画像が保存先にあれば置き換える
+	replace := func(u string) string {
+		result, ok := results[u]
+		if !ok || result.OK() {
+			return u
+		}
+		if moved, ok := c.findMoved(ctx, u); ok {
+			results[moved] = Result{URL: moved, Key: path.Base(u)}
+			changed = true
+			return moved
+		}
+		return u
+	}
+	comic.ImagePath = replace(comic.ImagePath)
+
+	if comic.Images != nil {
+		var sources []entity.ImageSource
+		for _, source := range comic.Images.Sources {
+			var variants []entity.ImageVariant
+			for _, variant := range source.Variants {
+				variant.URL = replace(variant.URL)
+				if results[variant.URL].OK() {
+					variants = append(variants, variant)
+				} else {
+					changed = true
+				}
+			}
+			if len(variants) > 0 {
+				source.Variants = variants
+				source.SrcSet = imagepipeline.SrcSet(variants)
+				sources = append(sources, source)
+			}
+		}
+		comic.Images.Sources = sources
+		comic.Images.Src = replace(comic.Images.Src)
+
+		// 残った画像のうち最も大きいJPEGを、srcsetに対応しないクライアント向けにする
+		largest := ""
+		for _, source := range sources {
+			if source.Type == "image/jpeg" {
+				largest = source.Variants[len(source.Variants)-1].URL
+			}
+		}
+		if !results[comic.Images.Src].OK() && largest != "" {
+			comic.Images.Src = largest
+			changed = true
+		}
+		if comic.ImagePath != "" && !results[comic.ImagePath].OK() && largest != "" {
+			comic.ImagePath = largest
+			changed = true
+		}
+		if len(sources) == 0 && !results[comic.Images.Src].OK() {
+			comic.Images = nil
+			changed = true
+		}
+	}
+
+	// 置き換えた結果で確認し直す
+	report.Results = report.Results[:0]
+	for _, u := range ImageURLs(comic) {
+		report.Results = append(report.Results, results[u])
+	}
+	return changed
+}
+
+// findMoved は取得できないURLと同じファイル名の画像が保存先にあれば、そのURLを返します。
+func (c *Checker) findMoved(ctx context.Context, imageURL string) (string, bool) {
+	if c.Store == nil {
+		return "", false
+	}
+	parsed, err := url.Parse(imageURL)
+	if err != nil {
+		return "", false
+	}
+	key := path.Base(parsed.Path)
+	if !imagestore.ValidKey(key) || c.Store.URL(key) == imageURL {
+		return "", false
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if _, err := c.Store.Stat(ctx, key); err != nil {
+		return "", false
+	}
+	return c.Store.URL(key), true
+}
+
+// ImageURLs は漫画が参照する全ての画像のURLを重複なく返します。
+func ImageURLs(comic *entity.Comic) []string {
+	var urls []string
+	seen := map[string]bool{}
+	add := func(u string) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	add(comic.ImagePath)
+	if comic.Images != nil {
+		add(comic.Images.Src)
+		for _, source := range comic.Images.Sources {
+			for _, variant := range source.Variants {
+				add(variant.URL)
+			}
+		}
+	}
+	return urls
+}
@@ -0,0 +1,237 @@
+package imagecheck
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/imagestore"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const storeURL = "https://cdn.example.com/images"
+
+// newStore は画像をkeysのファイル名で保存したローカルの保存先を生成します。
+func newStore(t *testing.T, keys ...string) imagestore.ImageStore {
+	t.Helper()
+	store, err := imagestore.NewLocalStore(t.TempDir(), storeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := store.Put(context.Background(), key, strings.NewReader("image"), "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// request はテスト用のサーバーが受けたリクエストです。
+type request struct {
+	Method string
+	Range  string
+}
+
+func TestCheckURLFallsBackToRangedGet(t *testing.T) {
+	tests := []struct {
+		name       string
+		headStatus int
+		getStatus  int
+		wantStatus int
+		wantMethod []string
+	}{
+		{"HEAD succeeds", http.StatusOK, 0, http.StatusOK, []string{"HEAD"}},
+		{"HEAD not found is not retried", http.StatusNotFound, 0, http.StatusNotFound, []string{"HEAD"}},
+		{"405 falls back to GET", http.StatusMethodNotAllowed, http.StatusPartialContent, http.StatusPartialContent, []string{"HEAD", "GET"}},
+		{"501 falls back to GET", http.StatusNotImplemented, http.StatusOK, http.StatusOK, []string{"HEAD", "GET"}},
+		{"GET after 405 can fail", http.StatusMethodNotAllowed, http.StatusNotFound, http.StatusNotFound, []string{"HEAD", "GET"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				got = append(got, request{Method: r.Method, Range: r.Header.Get("Range")})
+				mu.Unlock()
+				if r.Method == http.MethodHead {
+					w.WriteHeader(tt.headStatus)
+					return
+				}
+				w.WriteHeader(tt.getStatus)
+				w.Write([]byte("x"))
+			}))
+			defer server.Close()
+
+			c := &Checker{Client: server.Client(), Timeout: time.Second}
+			result := c.CheckURL(context.Background(), server.URL+"/cover.jpg")
+
+			if result.Err != nil || result.Status != tt.wantStatus {
+				t.Fatalf("CheckURL = %+v, want status %d", result, tt.wantStatus)
+			}
+			if result.OK() != (tt.wantStatus < 300) {
+				t.Errorf("OK() = %v for status %d", result.OK(), tt.wantStatus)
+			}
+			var methods []string
+			for _, r := range got {
+				methods = append(methods, r.Method)
+				// GETは画像全体ではなく先頭の1バイトだけを要求する
+				if r.Method == http.MethodGet && r.Range != "bytes=0-0" {
+					t.Errorf("GET Range = %q, want bytes=0-0", r.Range)
+				}
+			}
+			if !reflect.DeepEqual(methods, tt.wantMethod) {
+				t.Errorf("requests = %v, want %v", methods, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestCheckURLTimeoutIsPerURL(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/slow") {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	const timeout = 100 * time.Millisecond
+	c := &Checker{Client: server.Client(), Concurrency: 1, Timeout: timeout}
+	urls := []string{server.URL + "/slow1.jpg", server.URL + "/slow2.jpg", server.URL + "/slow3.jpg", server.URL + "/fast.jpg"}
+
+	start := time.Now()
+	results := c.CheckURLs(context.Background(), urls)
+	elapsed := time.Since(start)
+
+	for _, u := range urls[:3] {
+		if result := results[u]; !errors.Is(result.Err, context.DeadlineExceeded) {
+			t.Errorf("%s: err = %v, want context.DeadlineExceeded", u, result.Err)
+		}
+	}
+	// 遅いURLで時間を使い切っても、後のURLは自分の時間で確認する
+	if result := results[urls[3]]; !result.OK() {
+		t.Errorf("%s after slow URLs = %+v, want ok", urls[3], result)
+	}
+	if elapsed < 3*timeout || elapsed > 3*timeout+2*time.Second {
+		t.Errorf("checking 3 slow URLs one at a time took %s, want about %s", elapsed, 3*timeout)
+	}
+}
+
+func TestCheckURLUsesStore(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	c := &Checker{Store: newStore(t, "abc.jpg"), Client: server.Client()}
+
+	if result := c.CheckURL(context.Background(), storeURL+"/abc.jpg"); !result.OK() || result.Key != "abc.jpg" {
+		t.Errorf("stored image = %+v, want ok with key abc.jpg", result)
+	}
+	if result := c.CheckURL(context.Background(), storeURL+"/missing.jpg"); !errors.Is(result.Err, imagestore.ErrNotFound) {
+		t.Errorf("missing image: err = %v, want ErrNotFound", result.Err)
+	}
+	if result := c.CheckURL(context.Background(), "file:///etc/passwd"); !errors.Is(result.Err, ErrNotStoreURL) {
+		t.Errorf("file URL: err = %v, want ErrNotStoreURL", result.Err)
+	}
+	if requests != 0 {
+		t.Errorf("the store URLs were fetched over HTTP %d times", requests)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	// 旧配信元は全て404を返す
+	oldCDN := httptest.NewServer(http.NotFoundHandler())
+	defer oldCDN.Close()
+	store := newStore(t, "abc.jpg", "abc-240.jpg", "abc-240.webp")
+	c := &Checker{Store: store, Client: oldCDN.Client(), Timeout: time.Second}
+
+	comic := &entity.Comic{
+		ID: 1,
+		// 配信元が変わったが、同じファイル名の画像が保存先にある
+		ImagePath: oldCDN.URL + "/covers/abc.jpg",
+		Images: &entity.ImageSet{
+			Src: storeURL + "/abc-480.jpg",
+			Sources: []entity.ImageSource{
+				{Type: "image/webp", Variants: []entity.ImageVariant{
+					{Width: 240, URL: storeURL + "/abc-240.webp"},
+					{Width: 480, URL: storeURL + "/abc-480.webp"},
+				}},
+				{Type: "image/avif", Variants: []entity.ImageVariant{
+					{Width: 240, URL: storeURL + "/abc-240.avif"},
+				}},
+				{Type: "image/jpeg", Variants: []entity.ImageVariant{
+					{Width: 240, URL: storeURL + "/abc-240.jpg"},
+					{Width: 480, URL: storeURL + "/abc-480.jpg"},
+				}},
+			},
+		},
+	}
+	reports := c.Check(context.Background(), []*entity.Comic{comic})
+	report := &reports[0]
+	if !report.Broken() {
+		t.Fatal("the comic should be broken before Repair")
+	}
+
+	if !c.Repair(context.Background(), report) {
+		t.Fatal("Repair returned false")
+	}
+	if report.Broken() {
+		t.Errorf("the comic is still broken after Repair: %+v", report.Results)
+	}
+	if comic.ImagePath != storeURL+"/abc.jpg" {
+		t.Errorf("ImagePath = %s, want the stored image", comic.ImagePath)
+	}
+	// 取得できるJPEGのうち最も大きいものをsrcにする
+	if comic.Images.Src != storeURL+"/abc-240.jpg" {
+		t.Errorf("Images.Src = %s, want the largest remaining JPEG", comic.Images.Src)
+	}
+	want := []entity.ImageSource{
+		{Type: "image/webp", SrcSet: storeURL + "/abc-240.webp 240w", Variants: []entity.ImageVariant{{Width: 240, URL: storeURL + "/abc-240.webp"}}},
+		{Type: "image/jpeg", SrcSet: storeURL + "/abc-240.jpg 240w", Variants: []entity.ImageVariant{{Width: 240, URL: storeURL + "/abc-240.jpg"}}},
+	}
+	if !reflect.DeepEqual(comic.Images.Sources, want) {
+		t.Errorf("Sources = %+v, want %+v", comic.Images.Sources, want)
+	}
+
+	// 直すものがなければ書き換えない
+	if c.Repair(context.Background(), report) {
+		t.Error("Repair of a healthy comic returned true")
+	}
+}
+
+func TestRepairDropsImagesWhenNothingIsLeft(t *testing.T) {
+	c := &Checker{Store: newStore(t)}
+	comic := &entity.Comic{
+		ID:        1,
+		ImagePath: storeURL + "/gone.jpg",
+		Images: &entity.ImageSet{
+			Src:     storeURL + "/gone-480.jpg",
+			Sources: []entity.ImageSource{{Type: "image/jpeg", Variants: []entity.ImageVariant{{Width: 480, URL: storeURL + "/gone-480.jpg"}}}},
+		},
+	}
+	reports := c.Check(context.Background(), []*entity.Comic{comic})
+
+	if !c.Repair(context.Background(), &reports[0]) {
+		t.Fatal("Repair returned false")
+	}
+	if comic.Images != nil {
+		t.Errorf("Images = %+v, want nil", comic.Images)
+	}
+	// 置き換え先のない画像はBrokenのまま報告する
+	if !reports[0].Broken() || comic.ImagePath != storeURL+"/gone.jpg" {
+		t.Errorf("ImagePath = %s, Broken = %v", comic.ImagePath, reports[0].Broken())
+	}
+}
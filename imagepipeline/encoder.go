@@ -0,0 +1,103 @@
+package imagepipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// ErrNoWebPEncoder はWebPを書き出すcwebpコマンドが見つからないことを表します。
+var ErrNoWebPEncoder = errors.New("cwebp not found")
+
+// Encoder は画像を1つの形式で書き出します。
+type Encoder interface {
+	ContentType() string
+	Ext() string
+	Encode(w io.Writer, img image.Image) error
+}
+
+// JPEGEncoder は画像をJPEGで書き出します。
+type JPEGEncoder struct {
+	Quality int
+}
+
+func (e JPEGEncoder) ContentType() string { return "image/jpeg" }
+func (e JPEGEncoder) Ext() string         { return ".jpg" }
+
+func (e JPEGEncoder) Encode(w io.Writer, img image.Image) error {
+	quality := e.Quality
+	if quality == 0 {
+		quality = 82
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+// CWebPEncoder はlibwebpのcwebpコマンドで画像をWebPで書き出します。
+// Goだけで書かれた非可逆のWebPエンコーダーはこのモジュールのGoのバージョンでは使えないため、外部コマンドを使用します。
+type CWebPEncoder struct {
+	Path    string
+	Quality int
+}
+
+// NewCWebPEncoder はPATHからcwebpを探します。見つからない場合はErrNoWebPEncoderを返します。
+func NewCWebPEncoder(quality int) (*CWebPEncoder, error) {
+	path, err := exec.LookPath("cwebp")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoWebPEncoder, err)
+	}
+	return &CWebPEncoder{Path: path, Quality: quality}, nil
+}
+
+func (e *CWebPEncoder) ContentType() string { return "image/webp" }
+func (e *CWebPEncoder) Ext() string         { return ".webp" }
+
+func (e *CWebPEncoder) Encode(w io.Writer, img image.Image) error {
+	quality := e.Quality
+	if quality == 0 {
+		quality = 80
+	}
+
+	// cwebpには劣化のないPNGで渡す。標準入出力に対応しない古いcwebpもあるため一時ファイルを使う
+	dir, err := os.MkdirTemp("", "cwebp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out.webp")
+	file, err := os.Create(in)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(e.Path, "-quiet", "-q", strconv.Itoa(quality), "-o", out, in)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cwebp: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	encoded, err := os.Open(out)
+	if err != nil {
+		return err
+	}
+	defer encoded.Close()
+	_, err = io.Copy(w, encoded)
+	return err
+}
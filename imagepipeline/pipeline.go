@@ -0,0 +1,234 @@
+package imagepipeline
+
+import (
+	"bytes"
+	"comic-summaries/entity"
+	"comic-summaries/imagestore"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultWidths は既定で書き出す画像の幅です。
+var DefaultWidths = []int{160, 320, 640}
+
+// MaxPixels は読み込む画像の画素数の上限です。巨大な画像でメモリを使い切らないようにします。
+const MaxPixels = 40_000_000
+
+// ErrUnsupported は画像として読み込めない形式であることを表します。
+var ErrUnsupported = errors.New("unsupported image format")
+
+// extensions は元画像を保存するときの形式ごとの拡張子です。
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Pipeline は元画像を保存し、幅と形式の違う画像を書き出します。
+type Pipeline struct {
+	Store imagestore.ImageStore
+	// Widths は書き出す画像の幅です。元画像より大きい幅は書き出しません。既定値はDefaultWidthsです。
+	Widths []int
+	// Encoders は書き出す形式です。先頭の形式ほど優先してsrcsetに並べます。既定値はJPEGのみです。
+	Encoders []Encoder
+}
+
+// New はstoreに保存するPipelineを生成します。cwebpがあればWebPとJPEG、なければJPEGのみを書き出します。
+// cwebpがない場合は、WebPを書き出さないことに気付けるようログに残します。
+func New(store imagestore.ImageStore, widths []int) *Pipeline {
+	p := &Pipeline{Store: store, Widths: widths}
+	if webp, err := NewCWebPEncoder(0); err == nil {
+		p.Encoders = append(p.Encoders, webp)
+	} else {
+		log.Printf("Writing JPEG images only, install libwebp (cwebp) to also write WebP: %v", err)
+	}
+	p.Encoders = append(p.Encoders, JPEGEncoder{})
+	return p
+}
+
+// ContentKey は画像の内容のSHA-256をキーのbaseとして返します。同じ画像は同じキーになるため、取り込みを繰り返しても同じ画像を保存し直しません。
+func ContentKey(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Result はProcessで保存した画像です。
+type Result struct {
+	// URL は元画像のURLです。
+	URL         string
+	Images      *entity.ImageSet
+	Placeholder Placeholder
+}
+
+// Process は画像の実際の形式を判定して元画像をbase+拡張子のキーで保存し、書き出した画像の一覧とプレースホルダーを返します。
+// 既に保存されているキーの画像は保存し直しません。
+func (p *Pipeline) Process(ctx context.Context, base string, data []byte) (*Result, error) {
+	img, contentType, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	originalKey := base + extensions[contentType]
+	exists, err := p.exists(ctx, originalKey)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := p.Store.Put(ctx, originalKey, bytes.NewReader(data), contentType); err != nil {
+			return nil, err
+		}
+	}
+
+	bounds := img.Bounds()
+	set := &entity.ImageSet{
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	}
+
+	widths := p.widths(bounds.Dx())
+	encoders := p.Encoders
+	if len(encoders) == 0 {
+		encoders = []Encoder{JPEGEncoder{}}
+	}
+	set.Sources = make([]entity.ImageSource, len(encoders))
+	for i, encoder := range encoders {
+		set.Sources[i].Type = encoder.ContentType()
+	}
+	for _, width := range widths {
+		resized := resize(img, width)
+		for i, encoder := range encoders {
+			variant, err := p.writeVariant(ctx, base, resized, encoder)
+			if err != nil {
+				return nil, err
+			}
+			set.Sources[i].Variants = append(set.Sources[i].Variants, variant)
+		}
+	}
+	for i := range set.Sources {
+		set.Sources[i].SrcSet = SrcSet(set.Sources[i].Variants)
+	}
+
+	// srcsetに対応しないクライアントには最も大きいJPEGを返す
+	set.Src = p.Store.URL(originalKey)
+	for _, source := range set.Sources {
+		if source.Type == "image/jpeg" && len(source.Variants) > 0 {
+			set.Src = source.Variants[len(source.Variants)-1].URL
+		}
+	}
+	placeholder, err := ComputePlaceholder(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{URL: p.Store.URL(originalKey), Images: set, Placeholder: placeholder}, nil
+}
+
+// Decode は画像の実際の形式を判定して読み込みます。戻り値の文字列は判定した形式です。
+func Decode(data []byte) (image.Image, string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupported, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, "", fmt.Errorf("image too large: %dx%d", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	return img, contentType, nil
+}
+
+// widths は元画像の幅がsourceWidthの場合に書き出す幅を返します。
+// 元画像が全ての幅より小さい場合は元画像の幅で1つだけ書き出します。
+func (p *Pipeline) widths(sourceWidth int) []int {
+	candidates := p.Widths
+	if len(candidates) == 0 {
+		candidates = DefaultWidths
+	}
+	var widths []int
+	for _, width := range candidates {
+		if width > 0 && width <= sourceWidth {
+			widths = append(widths, width)
+		}
+	}
+	if len(widths) == 0 {
+		widths = []int{sourceWidth}
+	}
+	return widths
+}
+
+// resize は画像を縦横比を保ったまま幅widthに縮小します。
+func resize(img image.Image, width int) *image.RGBA {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	return scale(img, bounds, width, height)
+}
+
+// scale は画像のsrcの範囲を幅width・高さheightに拡大縮小します。
+func scale(img image.Image, src image.Rectangle, width int, height int) *image.RGBA {
+	// JPEGは透過を扱えないため、白い背景に重ねて縮小する
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(resized, resized.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, src, draw.Over, nil)
+	return resized
+}
+
+// writeVariant は縮小した画像をencoderの形式で保存します。同じキーの画像が既にあれば書き出しません。
+func (p *Pipeline) writeVariant(ctx context.Context, base string, img *image.RGBA, encoder Encoder) (entity.ImageVariant, error) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	key := base + "-" + strconv.Itoa(width) + "w" + encoder.Ext()
+	variant := entity.ImageVariant{Width: width, Height: height, URL: p.Store.URL(key)}
+	exists, err := p.exists(ctx, key)
+	if err != nil || exists {
+		return variant, err
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, img); err != nil {
+		return entity.ImageVariant{}, err
+	}
+	if err := p.Store.Put(ctx, key, &buf, encoder.ContentType()); err != nil {
+		return entity.ImageVariant{}, err
+	}
+	return variant, nil
+}
+
+// exists はキーの画像が保存されているかを返します。
+func (p *Pipeline) exists(ctx context.Context, key string) (bool, error) {
+	_, err := p.Store.Stat(ctx, key)
+	if errors.Is(err, imagestore.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SrcSet は画像の一覧をsrcset属性の値 ("a.jpg 160w, b.jpg 320w") にします。
+func SrcSet(variants []entity.ImageVariant) string {
+	parts := make([]string, len(variants))
+	for i, v := range variants {
+		parts[i] = v.URL + " " + strconv.Itoa(v.Width) + "w"
+	}
+	return strings.Join(parts, ", ")
+}
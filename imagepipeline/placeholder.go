@@ -0,0 +1,184 @@
+package imagepipeline
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// BlurHashComponents は表紙のBlurHashの横と縦の成分の数です。縦長の表紙に合わせて縦を多くします。
+var BlurHashComponents = [2]int{3, 4}
+
+// placeholderWidth はプレースホルダーを計算する前に縮小する幅です。小さくしても結果はほとんど変わりません。
+const placeholderWidth = 32
+
+// Placeholder は画像を読み込むまでに表示するBlurHashと代表色です。
+type Placeholder struct {
+	BlurHash string
+	// DominantColor は "#rrggbb" 形式の代表色です。
+	DominantColor string
+}
+
+// ComputePlaceholder は画像のBlurHashと代表色を計算します。
+func ComputePlaceholder(img image.Image) (Placeholder, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return Placeholder{}, fmt.Errorf("empty image")
+	}
+	small := img
+	if bounds.Dx() > placeholderWidth {
+		small = resize(img, placeholderWidth)
+	}
+
+	hash, err := BlurHash(small, BlurHashComponents[0], BlurHashComponents[1])
+	if err != nil {
+		return Placeholder{}, err
+	}
+	return Placeholder{BlurHash: hash, DominantColor: DominantColor(small)}, nil
+}
+
+// DominantColor は画像で最も多い色を "#rrggbb" 形式で返します。
+// 色を各チャンネル16段階にまとめて最も画素の多い組を選び、その組の画素の平均を代表色とします。
+func DominantColor(img image.Image) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := map[int]*bucket{}
+	var best *bucket
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b := rgb8(img, x, y)
+			key := (r>>4)<<8 | (g>>4)<<4 | b>>4
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.count++
+			bk.r += r
+			bk.g += g
+			bk.b += b
+			if best == nil || bk.count > best.count {
+				best = bk
+			}
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
+
+// BlurHash は画像をBlurHash (https://blurha.sh) の文字列にします。xとyは横と縦の成分の数で、1から9までです。
+func BlurHash(img image.Image, xComponents int, yComponents int) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", fmt.Errorf("blurhash components must be between 1 and 9: %dx%d", xComponents, yComponents)
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return "", fmt.Errorf("empty image")
+	}
+
+	// 画素をリニアなRGBにしておく
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b := rgb8(img, bounds.Min.X+x, bounds.Min.Y+y)
+			linear[y*width+x] = [3]float64{srgbToLinear(r), srgbToLinear(g), srgbToLinear(b)}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var factor [3]float64
+			for y := 0; y < height; y++ {
+				cosY := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+				for x := 0; x < width; x++ {
+					basis := normalisation * math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) * cosY
+					pixel := linear[y*width+x]
+					factor[0] += basis * pixel[0]
+					factor[1] += basis * pixel[1]
+					factor[2] += basis * pixel[2]
+				}
+			}
+			scale := 1 / float64(width*height)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maximumValue = float64(quantisedMax+1) / 166
+		hash.WriteString(encode83(quantisedMax, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		quantise := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encode83(quantise(f[0])*19*19+quantise(f[1])*19+quantise(f[2]), 2))
+	}
+	return hash.String(), nil
+}
+
+// rgb8 は画素の色を0から255のRGBで返します。透過している部分は白い背景に重ねた色にします。
+func rgb8(img image.Image, x int, y int) (int, int, int) {
+	r, g, b, a := img.At(x, y).RGBA()
+	// RGBAはアルファを掛けた値のため、白を重ねるには足りない分を足す
+	white := 0xffff - a
+	return int((r + white) >> 8), int((g + white) >> 8), int((b + white) >> 8)
+}
+
+func srgbToLinear(value int) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(math.Round(v * 12.92 * 255))
+	}
+	return int(math.Round((1.055*math.Pow(v, 1/2.4) - 0.055) * 255))
+}
+
+func signPow(value float64, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+const base83Characters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// encode83 は値をBlurHashのbase83でlength文字にします。
+func encode83(value int, length int) string {
+	out := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		out[i] = base83Characters[value%83]
+		value /= 83
+	}
+	return string(out)
+}
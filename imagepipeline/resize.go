@@ -0,0 +1,96 @@
+package imagepipeline
+
+import (
+	"fmt"
+	"image"
+)
+
+// 縮小後の大きさの決め方を表すFitの値です。
+const (
+	// FitContain は縦横比を保ったまま指定した枠に収めます。既定値です。
+	FitContain = "contain"
+	// FitCover は縦横比を保ったまま枠を覆うように縮小し、はみ出した部分を中央から切り取ります。
+	FitCover = "cover"
+	// FitFill は縦横比を無視して枠の大きさにします。
+	FitFill = "fill"
+)
+
+// ValidFit はFitとして使える値であるかを返します。
+func ValidFit(fit string) bool {
+	switch fit {
+	case FitContain, FitCover, FitFill:
+		return true
+	}
+	return false
+}
+
+// Resize は画像を幅width・高さheightの枠に合わせて縮小します。0の辺は縦横比から決めます。
+// 元画像より大きくはしません。枠が元画像より大きい場合は、枠の縦横比を保ったまま縮めます。
+func Resize(img image.Image, width int, height int, fit string) (*image.RGBA, error) {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	if width < 0 || height < 0 || (width == 0 && height == 0) {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	if fit == "" {
+		fit = FitContain
+	}
+	if !ValidFit(fit) {
+		return nil, fmt.Errorf("unknown fit %q", fit)
+	}
+
+	// 片方の辺だけ指定された場合は枠に収めるのと同じになる
+	if width == 0 || height == 0 {
+		fit = FitContain
+		if width == 0 {
+			width = sw
+		}
+		if height == 0 {
+			height = sh
+		}
+	}
+
+	src := bounds
+	switch fit {
+	case FitContain:
+		// 枠に収まるよう小さい方の倍率に合わせる
+		if width*sh < height*sw {
+			height = max(1, sh*width/sw)
+		} else {
+			width = max(1, sw*height/sh)
+		}
+		// 縦横比は元画像と同じなので、はみ出す場合は元画像の大きさになる
+		if width > sw || height > sh {
+			width, height = sw, sh
+		}
+	case FitCover, FitFill:
+		// 枠が元画像からはみ出す場合は、枠の縦横比を保ったまま元画像に収まるまで縮める
+		if width > sw || height > sh {
+			if width*sh > height*sw {
+				width, height = sw, max(1, height*sw/width)
+			} else {
+				width, height = max(1, width*sh/height), sh
+			}
+		}
+	}
+
+	if fit == FitCover {
+		// 枠と同じ縦横比の範囲を元画像の中央から切り取る
+		if width*sh < height*sw {
+			cw := max(1, sh*width/height)
+			src = image.Rect(bounds.Min.X+(sw-cw)/2, bounds.Min.Y, bounds.Min.X+(sw-cw)/2+cw, bounds.Max.Y)
+		} else {
+			ch := max(1, sw*height/width)
+			src = image.Rect(bounds.Min.X, bounds.Min.Y+(sh-ch)/2, bounds.Max.X, bounds.Min.Y+(sh-ch)/2+ch)
+		}
+	}
+
+	return scale(img, src, width, height), nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
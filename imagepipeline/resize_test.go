@@ -0,0 +1,59 @@
+package imagepipeline
+
+import (
+	"image"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	tests := []struct {
+		name          string
+		width, height int
+		fit           string
+		wantW, wantH  int
+	}{
+		{"contain", 200, 200, FitContain, 200, 100},
+		{"contain a box wider than the original", 2000, 400, FitContain, 800, 400},
+		{"contain a box larger than the original", 2000, 2000, FitContain, 1000, 500},
+		{"width only", 400, 0, "", 400, 200},
+		{"height only", 0, 100, FitCover, 200, 100},
+		{"width only larger than the original", 3000, 0, "", 1000, 500},
+		{"cover", 200, 200, FitCover, 200, 200},
+		{"fill", 300, 300, FitFill, 300, 300},
+
+		// 枠が元画像からはみ出す場合は、枠の縦横比を保ったまま縮める
+		{"cover a square box larger than the original", 2000, 2000, FitCover, 500, 500},
+		{"cover a box wider than the original", 2000, 400, FitCover, 1000, 200},
+		{"cover a box taller than the original", 400, 800, FitCover, 250, 500},
+		{"fill a square box larger than the original", 2000, 2000, FitFill, 500, 500},
+		{"fill a box wider than the original", 1200, 100, FitFill, 1000, 83},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resize(src, tt.width, tt.height, tt.fit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w, h := got.Bounds().Dx(), got.Bounds().Dy(); w != tt.wantW || h != tt.wantH {
+				t.Errorf("Resize(%dx%d, %s) = %dx%d, want %dx%d", tt.width, tt.height, tt.fit, w, h, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestResizeRejectsInvalidSize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for _, tt := range []struct {
+		width, height int
+		fit           string
+	}{
+		{0, 0, FitContain},
+		{-1, 10, FitContain},
+		{10, 10, "stretch"},
+	} {
+		if _, err := Resize(src, tt.width, tt.height, tt.fit); err == nil {
+			t.Errorf("Resize(%dx%d, %q) returned no error", tt.width, tt.height, tt.fit)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package imagestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound は指定したキーの画像がないことを表します。
+var ErrNotFound = errors.New("image not found")
+
+// ErrInvalidKey は画像のキーとして使えない値であることを表します。
+var ErrInvalidKey = errors.New("invalid image key")
+
+// Object は読み出した画像です。読み終わったらCloseしてください。
+type Object struct {
+	io.ReadCloser
+	ContentType string
+	// Size は画像のバイト数です。不明な場合は-1です。
+	Size    int64
+	ModTime time.Time
+}
+
+// ObjectInfo は保存している画像の情報です。
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// ImageStore は漫画の画像の保存先です。キーは "abc.jpg" や "covers/abc.jpg" のような / 区切りの相対パスです。
+type ImageStore interface {
+	// Put は画像をキーで保存します。同じキーの画像は置き換えます。
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get はキーの画像を返します。画像がない場合はErrNotFoundを返します。
+	Get(ctx context.Context, key string) (*Object, error)
+	// Stat はキーの画像の情報を返します。画像がない場合はErrNotFoundを返します。
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete はキーの画像を削除します。画像がない場合も成功します。
+	Delete(ctx context.Context, key string) error
+	// List は保存している全ての画像についてfnを呼び出します。fnがエラーを返した場合はそこで止めます。
+	List(ctx context.Context, fn func(ObjectInfo) error) error
+	// URL はクライアントが画像を取得するためのURLを返します。
+	URL(key string) string
+	// Ping は保存先に接続でき、使える状態であるかを確認します。
+	Ping(ctx context.Context) error
+}
+
+// KeyFromURL はstoreのURLから画像のキーを返します。storeの画像のURLでない場合はfalseを返します。
+func KeyFromURL(store ImageStore, imageURL string) (string, bool) {
+	key, ok := strings.CutPrefix(imageURL, store.URL(""))
+	if !ok || !ValidKey(key) {
+		return "", false
+	}
+	return key, true
+}
+
+// ValidKey はキーが保存先の外を指さない相対パスであるかを返します。
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	return path.Clean(key) == key && key != "." && !strings.HasPrefix(key, "../") && key != ".."
+}
+
+// Config は保存先の設定です。
+type Config struct {
+	// Backend は "local" または "s3" です。既定値は "local" です。
+	Backend string
+	// Dir はlocalの保存先のディレクトリです。既定値は "images" です。
+	Dir string
+	// BaseURL は画像のURLの前に付けるURLです。localの既定値はAPIが画像を配信する "/images" です。
+	// s3ではCloudFrontなどの配信元のURLを指定します。空の場合はS3のURLを使います。
+	BaseURL string
+	// Bucket はs3の保存先のバケットです。既定値は "comic-summaries" です。
+	Bucket string
+	// Region はs3のリージョンです。
+	Region string
+	// Endpoint はS3互換のストレージ(MinIOなど)に接続する場合のエンドポイントです。
+	Endpoint string
+}
+
+// New は設定に応じたImageStoreを生成します。
+func New(ctx context.Context, cfg Config) (ImageStore, error) {
+	switch cfg.Backend {
+	case "", "local":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = "images"
+		}
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = "/images"
+		}
+		return NewLocalStore(dir, baseURL)
+	case "s3":
+		bucket := cfg.Bucket
+		if bucket == "" {
+			bucket = "comic-summaries"
+		}
+		client, err := NewS3Client(ctx, cfg.Region, cfg.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3Store(client, bucket, cfg.BaseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown image store %q (want local or s3)", cfg.Backend)
+	}
+}
+
+// joinURL はbaseURLとキーを / で連結します。
+func joinURL(baseURL string, key string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + key
+}
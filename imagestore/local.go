@@ -0,0 +1,173 @@
+package imagestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localStore はローカルのディレクトリに画像を保存します。画像はAPIが配信します。
+type localStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStore はdirに画像を保存するImageStoreを生成します。dirがなければ作成します。
+func NewLocalStore(dir string, baseURL string) (ImageStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &localStore{dir: dir, baseURL: baseURL}, nil
+}
+
+func (s *localStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	filename := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+
+	// 書き込み途中のファイルが配信されないよう、一時ファイルに書いてから置き換える
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+func (s *localStore) Get(ctx context.Context, key string) (*Object, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	file, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, ErrNotFound
+	}
+
+	contentType, err := detectContentType(key, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Object{
+		ReadCloser:  file,
+		ContentType: contentType,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+func (s *localStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	info, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *localStore) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	return filepath.WalkDir(s.dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// 書き込み途中の一時ファイルは含めない
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+	})
+}
+
+func (s *localStore) Ping(ctx context.Context) error {
+	info, err := os.Stat(s.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.dir)
+	}
+	return nil
+}
+
+func (s *localStore) URL(key string) string {
+	return joinURL(s.baseURL, key)
+}
+
+// detectContentType は拡張子から画像の形式を判定し、判定できなければ先頭のバイト列から判定します。
+func detectContentType(key string, file *os.File) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType, nil
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
@@ -0,0 +1,179 @@
+package imagestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"io"
+)
+
+// S3API はs3Storeが使用するS3クライアントのメソッドです。
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+}
+
+// s3Store はS3のバケットに画像を保存します。画像はCloudFrontなどの配信元から直接取得します。
+type s3Store struct {
+	client  S3API
+	bucket  string
+	baseURL string
+}
+
+// NewS3Store はbucketに画像を保存するImageStoreを生成します。baseURLが空の場合はS3のURLを返します。
+func NewS3Store(client S3API, bucket string, baseURL string) ImageStore {
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s.s3.amazonaws.com", bucket)
+	}
+	return &s3Store{client: client, bucket: bucket, baseURL: baseURL}
+}
+
+// NewS3Client はS3のクライアントを生成します。endpointを指定した場合はS3互換のストレージにパス形式で接続します。
+func NewS3Client(ctx context.Context, region string, endpoint string) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	// 署名と長さの計算に読み直せる本文が必要なため、HTTPレスポンスなどはメモリに読み込む
+	if _, ok := r.(io.ReadSeeker); !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	_, err := s.client.PutObject(ctx, input)
+	return err
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (*Object, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	object := &Object{
+		ReadCloser:  out.Body,
+		ContentType: aws.ToString(out.ContentType),
+		Size:        -1,
+	}
+	if out.ContentLength != nil {
+		object.Size = *out.ContentLength
+	}
+	if out.LastModified != nil {
+		object.ModTime = *out.LastModified
+	}
+	return object, nil
+}
+
+func (s *s3Store) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	// HEADのレスポンスには本文がないため、NoSuchKeyではなくNotFoundが返る
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ObjectInfo{Key: key, Size: -1}
+	if out.ContentLength != nil {
+		info.Size = *out.ContentLength
+	}
+	if out.LastModified != nil {
+		info.ModTime = *out.LastModified
+	}
+	return info, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *s3Store) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, object := range page.Contents {
+			info := ObjectInfo{Key: aws.ToString(object.Key), Size: -1}
+			if object.Size != nil {
+				info.Size = *object.Size
+			}
+			if object.LastModified != nil {
+				info.ModTime = *object.LastModified
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Ping はHeadBucketでバケットがあり、アクセスできることを確認します。
+func (s *s3Store) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
+
+func (s *s3Store) URL(key string) string {
+	return joinURL(s.baseURL, key)
+}
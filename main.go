@@ -1,13 +1,26 @@
 package main
 
 import (
+	"comic-summaries/comicio"
+	"comic-summaries/config"
 	"comic-summaries/controller"
+	"comic-summaries/entity"
 	"comic-summaries/handler"
+	"comic-summaries/imagecache"
+	"comic-summaries/imagestore"
+	"comic-summaries/metrics"
 	"comic-summaries/repository"
 	"comic-summaries/usecase"
-	"github.com/joho/godotenv"
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -17,43 +30,176 @@ func main() {
 	// Echoインスタンスの作成
 	e := echo.New()
 
-	// デバッグのため強制終了
-	if os.Getenv("GO_ENV") == "dev" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Fatalln(err)
+	// 設定の読み込み (既定値 < .env (GO_ENV=devのみ) < 環境変数 < コマンドライン引数)
+	loader := config.NewLoader(flag.CommandLine, config.ServerOptions)
+	flag.Parse()
+	cfg := loader.MustLoad()
+	// 開発環境以外ではフロントエンドのオリジンがないとCORSで全て拒否されるため必須にする
+	if cfg.Env != "dev" {
+		if err := cfg.Require("FRONTEND_ENDPOINT"); err != nil {
+			log.Fatalf("Invalid configuration:\n%v", err)
 		}
 	}
+	log.Printf("Configuration:\n%s", cfg)
+
+	// /metricsで公開するメトリクス
+	registry := metrics.NewRegistry()
 
 	// ミドルウェアの設定
-	e.Use(middleware.Logger())
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Skipper: func(c echo.Context) bool {
+			for _, path := range handler.HealthPaths {
+				if c.Path() == path {
+					return true
+				}
+			}
+			return false
+		},
+	}))
+	// パニックも500として数えるため、Recoverより外側で記録する
+	e.Use(handler.MetricsMiddleware(registry))
 	e.Use(middleware.Recover())
 
-	frontendEndPoint := os.Getenv("FRONTEND_ENDPOINT")
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{frontendEndPoint}, // Reactアプリのオリジン
+		AllowOrigins: []string{cfg.FrontendEndpoint}, // Reactアプリのオリジン
 		AllowMethods: []string{echo.GET, echo.PUT, echo.POST, echo.DELETE},
 	}))
 
-	// 静的ファイルの設定
-	e.Static("/images", "images")
+	// 画像の保存先の設定 (IMAGE_STORE=local|s3)
+	imageStore, err := imagestore.New(context.Background(), cfg.ImageStore())
+	if err != nil {
+		log.Fatalln(err)
+	}
 
 	// リポジトリのインスタンス化
 	// DynamoDBを使用する場合は、ここでDynamoDBクライアントを初期化してリポジトリに渡す
-	comicRepo := repository.NewComicRepository()
+	// REPOSITORY=memory の場合はDynamoDBを使わずメモリ上で動かす(MEMORY_SEED_FILEで初期データを指定できる)
+	var comicRepo repository.IComicRepository
+	var revisionRepo repository.IRevisionRepository
+	// closers は終了時に処理中のリクエストを待ってから解放するものです。
+	var closers []func() error
+	if cfg.Repository == "memory" {
+		var seed []entity.Comic
+		if seedFile := cfg.MemorySeedFile; seedFile != "" {
+			format, err := comicio.DetectFormat(seedFile)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if seed, err = comicio.ReadFile(seedFile, format); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		comicRepo = repository.NewMemoryComicRepository(seed)
+		revisionRepo = repository.NewMemoryRevisionRepository()
+	} else {
+		db := repository.NewDynamoDB(cfg.AWS)
+		// 管理画面の編集はリビジョンを記録するため、新しい環境でもテーブルを用意してから起動する (/readyzでも確認する)
+		ensureCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		err := repository.EnsureRevisionTable(ensureCtx, db)
+		cancel()
+		if err != nil {
+			log.Fatalf("Error preparing the revision table: %v", err)
+		}
+		comicRepo = repository.NewComicRepository(db)
+		revisionRepo = repository.NewRevisionRepository(db)
+		closers = append(closers, func() error {
+			repository.CloseDynamoDB(db)
+			return nil
+		})
+	}
+
+	comicRepo = repository.NewInstrumentedComicRepository(comicRepo, cfg.Repository, registry)
 
 	// ユースケースのインスタンス化
-	comicUsecase := usecase.NewComicUsecase(comicRepo)
+	comicUsecase := usecase.NewComicUsecase(comicRepo, revisionRepo)
 
 	comicController := controller.NewComicController(comicUsecase)
 
+	// 縮小した画像のディスクキャッシュの設定
+	imageCache, err := imagecache.New(cfg.Image.CacheDir, cfg.Image.CacheMaxBytes)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	closers = append(closers, imageCache.Close)
+	imageCache.RegisterMetrics(registry)
+	imageUsecase := usecase.NewImageUsecase(imageStore, imageCache)
+
+	imageController := controller.NewImageController(imageUsecase)
+
+	// 準備完了の確認 (/readyz) で確認する依存先
+	healthUsecase := usecase.NewHealthUsecase(cfg.Server.HealthCheckTimeout,
+		usecase.HealthCheck{Name: "comic_repository", Check: comicRepo.Ping},
+		usecase.HealthCheck{Name: "revision_repository", Check: revisionRepo.Ping},
+		usecase.HealthCheck{Name: "image_store", Check: imageStore.Ping},
+	)
+	healthController := controller.NewHealthController(healthUsecase)
+
 	// ハンドラの登録
 	handler.NewComicHandler(e, comicController)
+	handler.NewImageHandler(e, imageController)
+	handler.NewHealthHandler(e, healthController)
+
+	// 管理者向けのルートとメトリクスはトークンが設定されている場合のみ公開する
+	if adminToken := cfg.AdminToken; adminToken != "" {
+		handler.NewMetricsHandler(e, registry, adminToken)
+		revisionUsecase := usecase.NewRevisionUsecase(comicUsecase, revisionRepo)
+		reviewUsecase := usecase.NewReviewUsecase(comicUsecase, comicRepo)
+		adminController := controller.NewAdminController(comicUsecase, revisionUsecase, reviewUsecase)
+		handler.NewAdminHandler(e, adminController, adminToken)
+	} else {
+		log.Println("ADMIN_TOKEN is not set; admin routes and /metrics are disabled")
+	}
 
 	// サーバーの起動
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "1323" // デフォルトポート
+	e.Server.ReadHeaderTimeout = cfg.Server.ReadHeaderTimeout
+	e.Server.ReadTimeout = cfg.Server.ReadTimeout
+	e.Server.WriteTimeout = cfg.Server.WriteTimeout
+	e.Server.IdleTimeout = cfg.Server.IdleTimeout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// 2回目のシグナルでは待たずに終了できるよう、既定の動作に戻す
+		stop()
+	}()
+
+	var errs []error
+	if err := serve(ctx, e, ":"+strconv.Itoa(cfg.Port), cfg.Server.ShutdownTimeout); err != nil {
+		errs = append(errs, err)
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		errs = append(errs, closers[i]())
+	}
+	if err := errors.Join(errs...); err != nil {
+		log.Fatalf("Error shutting down: %v", err)
+	}
+	log.Println("Server stopped")
+}
+
+// serve はctxがキャンセルされるまでeでリクエストを受け付けます。キャンセルされたら新しい接続の受け付けを止め、
+// 処理中のリクエストが終わるのをshutdownTimeoutまで待ちます。待ちきれなかった接続は切ってエラーを返します。
+func serve(ctx context.Context, e *echo.Echo, address string, shutdownTimeout time.Duration) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.Start(address)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down; waiting up to %s for in-flight requests", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var errs []error
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err, e.Close())
+	}
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
 	}
-	e.Logger.Fatal(e.Start(":" + port))
+	return errors.Join(errs...)
 }
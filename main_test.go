@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// startSlowServer は/slowへのリクエストを受けるとstartedに知らせ、delay後に応答するサーバーをserveで起動します。
+func startSlowServer(t *testing.T, delay time.Duration, shutdownTimeout time.Duration) (addr string, started <-chan struct{}, cancel context.CancelFunc, done <-chan error) {
+	t.Helper()
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	startedCh := make(chan struct{}, 1)
+	e.GET("/slow", func(c echo.Context) error {
+		startedCh <- struct{}{}
+		time.Sleep(delay)
+		return c.String(http.StatusOK, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- serve(ctx, e, "127.0.0.1:0", shutdownTimeout)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		e.Close()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for e.ListenerAddr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("the server did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return e.ListenerAddr().String(), startedCh, cancel, doneCh
+}
+
+type response struct {
+	status int
+	body   string
+	err    error
+}
+
+func get(url string) <-chan response {
+	ch := make(chan response, 1)
+	go func() {
+		res, err := http.Get(url)
+		if err != nil {
+			ch <- response{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		ch <- response{status: res.StatusCode, body: string(body), err: err}
+	}()
+	return ch
+}
+
+func TestServeWaitsForInFlightRequests(t *testing.T) {
+	addr, started, cancel, done := startSlowServer(t, 300*time.Millisecond, 5*time.Second)
+
+	res := get("http://" + addr + "/slow")
+	<-started
+	cancel()
+
+	got := <-res
+	if got.err != nil || got.status != http.StatusOK || got.body != "done" {
+		t.Fatalf("in-flight request = %+v, want 200 done", got)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the shutdown")
+	}
+
+	// 停止後は新しい接続を受け付けない
+	if _, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		t.Error("the server still accepts connections after the shutdown")
+	}
+}
+
+func TestServeGivesUpAfterShutdownTimeout(t *testing.T) {
+	addr, started, cancel, done := startSlowServer(t, 2*time.Second, 100*time.Millisecond)
+
+	res := get("http://" + addr + "/slow")
+	<-started
+	start := time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("serve = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the shutdown timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("serve returned after %s, want about the shutdown timeout", elapsed)
+	}
+	// 待ちきれなかった接続は切られる
+	if got := <-res; got.err == nil {
+		t.Errorf("request = %+v, want a connection error", got)
+	}
+}
+
+func TestServeReturnsStartError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+
+	// 使用中のポートでは起動できず、シグナルを待たずに返る
+	if err := serve(context.Background(), e, ln.Addr().String(), time.Second); err == nil {
+		t.Error("serve on a port in use returned nil")
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentType はPrometheusのテキスト形式のContent-Typeです。
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteTo は登録した全てのメトリクスを登録順にPrometheusのテキスト形式で出力します。
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		b.WriteString("# HELP " + m.name + " " + escapeHelp(m.help) + "\n")
+		b.WriteString("# TYPE " + m.name + " " + m.typ + "\n")
+		m.write(&b)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler はメトリクスを返すhttp.Handlerです。
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		r.WriteTo(w)
+	})
+}
+
+// writeSample は1つの系列の行を出力します。leが空でない場合はヒストグラムのバケットとしてleラベルを付けます。
+func writeSample(b *strings.Builder, name string, labels []string, values []string, le string, v float64) {
+	b.WriteString(name)
+	if len(labels) > 0 || le != "" {
+		b.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(label + `="` + escapeLabelValue(values[i]) + `"`)
+		}
+		if le != "" {
+			if len(labels) > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(`le="` + le + `"`)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
@@ -0,0 +1,288 @@
+// Package metrics はPrometheusのテキスト形式で公開する、プロセス内のメトリクスです。
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets はレイテンシー (秒) のヒストグラムの既定の境界です。
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Registry はメトリクスをまとめて保持し、WriteToで出力します。同じ名前のメトリクスは登録できません。
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// metric は1つのメトリクスです。writeはHELPとTYPEの後の系列の行を出力します。
+type metric struct {
+	name  string
+	help  string
+	typ   string
+	write func(b *strings.Builder)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// register はメトリクスを登録します。名前が正しくないか重複している場合はプログラムの誤りのためpanicします。
+func (r *Registry) register(m metric, labels []string) {
+	if !namePattern.MatchString(m.name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", m.name))
+	}
+	for _, label := range labels {
+		if !namePattern.MatchString(label) || strings.Contains(label, ":") || label == "le" {
+			panic(fmt.Sprintf("metrics: invalid label name %q for %s", label, m.name))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[m.name] {
+		panic(fmt.Sprintf("metrics: %s is already registered", m.name))
+	}
+	r.names[m.name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Counter は増えるだけの値です。
+type Counter struct {
+	value atomicFloat
+}
+
+func (c *Counter) Inc() {
+	c.value.add(1)
+}
+
+// Add はvを足します。負の値は無視します。
+func (c *Counter) Add(v float64) {
+	if v > 0 {
+		c.value.add(v)
+	}
+}
+
+// Gauge は増減する値です。
+type Gauge struct {
+	value atomicFloat
+}
+
+func (g *Gauge) Set(v float64) {
+	g.value.store(v)
+}
+
+func (g *Gauge) Add(v float64) {
+	g.value.add(v)
+}
+
+func (g *Gauge) Inc() {
+	g.value.add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.add(-1)
+}
+
+// Histogram は観測した値の分布です。
+type Histogram struct {
+	upperBounds []float64
+	counts      []atomic.Uint64 // upperBoundsごとの件数 (累積ではない)。最後は+Infの分
+	sum         atomicFloat
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		upperBounds: buckets,
+		counts:      make([]atomic.Uint64, len(buckets)+1),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	h.counts[i].Add(1)
+	h.sum.add(v)
+}
+
+// vec はラベルの値ごとの系列です。
+type vec[T any] struct {
+	labels   []string
+	newValue func() *T
+
+	mu     sync.RWMutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	labelValues []string
+	value       *T
+}
+
+func newVec[T any](labels []string, newValue func() *T) *vec[T] {
+	return &vec[T]{
+		labels:   labels,
+		newValue: newValue,
+		series:   map[string]*series[T]{},
+	}
+}
+
+// with はラベルの値の系列を返します。初めての値の場合は作成します。
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(values), v.labels))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s.value
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.value
+	}
+	s = &series[T]{labelValues: append([]string(nil), values...), value: v.newValue()}
+	v.series[key] = s
+	return s.value
+}
+
+// sorted は出力が毎回同じ順になるよう、ラベルの値の順に並べた系列を返します。
+func (v *vec[T]) sorted() []*series[T] {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]*series[T], len(keys))
+	for i, key := range keys {
+		sorted[i] = v.series[key]
+	}
+	return sorted
+}
+
+// CounterVec はラベルの値ごとのCounterです。
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// With はラベルの値 (NewCounterVecのlabelsと同じ順) のCounterを返します。
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.with(values)
+}
+
+// GaugeVec はラベルの値ごとのGaugeです。
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+// With はラベルの値 (NewGaugeVecのlabelsと同じ順) のGaugeを返します。
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.with(values)
+}
+
+// HistogramVec はラベルの値ごとのHistogramです。
+type HistogramVec struct {
+	*vec[Histogram]
+}
+
+// With はラベルの値 (NewHistogramVecのlabelsと同じ順) のHistogramを返します。
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.with(values)
+}
+
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newVec(labels, func() *Counter { return &Counter{} })}
+	r.register(metric{name: name, help: help, typ: "counter", write: func(b *strings.Builder) {
+		for _, s := range v.sorted() {
+			writeSample(b, name, labels, s.labelValues, "", s.value.value.load())
+		}
+	}}, labels)
+	return v
+}
+
+func (r *Registry) NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{newVec(labels, func() *Gauge { return &Gauge{} })}
+	r.register(metric{name: name, help: help, typ: "gauge", write: func(b *strings.Builder) {
+		for _, s := range v.sorted() {
+			writeSample(b, name, labels, s.labelValues, "", s.value.value.load())
+		}
+	}}, labels)
+	return v
+}
+
+// NewHistogramVec はbuckets (昇順の上限値) で分布を数えるHistogramVecを登録します。bucketsがnilの場合はDefBucketsを使います。
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s are not sorted", name))
+	}
+	v := &HistogramVec{newVec(labels, func() *Histogram { return newHistogram(buckets) })}
+	r.register(metric{name: name, help: help, typ: "histogram", write: func(b *strings.Builder) {
+		for _, s := range v.sorted() {
+			h := s.value
+			// 観測中に読むため、件数はバケットの合計を使い_countと+Infを一致させる
+			var cumulative uint64
+			for i, upper := range h.upperBounds {
+				cumulative += h.counts[i].Load()
+				writeSample(b, name+"_bucket", labels, s.labelValues, formatFloat(upper), float64(cumulative))
+			}
+			cumulative += h.counts[len(h.upperBounds)].Load()
+			writeSample(b, name+"_bucket", labels, s.labelValues, "+Inf", float64(cumulative))
+			writeSample(b, name+"_sum", labels, s.labelValues, "", h.sum.load())
+			writeSample(b, name+"_count", labels, s.labelValues, "", float64(cumulative))
+		}
+	}}, labels)
+	return v
+}
+
+// NewCounterFunc は出力のたびにfnを呼び出して値を得るカウンターを登録します。他のパッケージが数えている値の公開に使います。
+func (r *Registry) NewCounterFunc(name string, help string, fn func() float64) {
+	r.register(metric{name: name, help: help, typ: "counter", write: func(b *strings.Builder) {
+		writeSample(b, name, nil, nil, "", fn())
+	}}, nil)
+}
+
+// NewGaugeFunc は出力のたびにfnを呼び出して値を得るゲージを登録します。
+func (r *Registry) NewGaugeFunc(name string, help string, fn func() float64) {
+	r.register(metric{name: name, help: help, typ: "gauge", write: func(b *strings.Builder) {
+		writeSample(b, name, nil, nil, "", fn())
+	}}, nil)
+}
+
+// atomicFloat はロックなしで足し込めるfloat64です。
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) store(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
@@ -0,0 +1,97 @@
+package prompt
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Latest は新しく生成する要約に使うプロンプトのバージョンです。
+// テンプレートを変更する場合は既存のファイルを編集せず、templates/に新しいバージョンを追加してここを更新します。
+const Latest = "v1"
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// Data はテンプレートに渡す変数です。
+type Data struct {
+	Title         string
+	Language      string
+	SynopsisMin   int
+	AttractionMin int
+	SpoilersMin   int
+}
+
+// Template はバージョン付きのプロンプトです。
+// テンプレートは "system" と "user" の2つのブロックを定義し、それぞれシステムメッセージとユーザーメッセージになります。
+type Template struct {
+	Version string
+	tmpl    *template.Template
+}
+
+// Load は埋め込まれたテンプレートからversionのプロンプトを読み込みます。
+func Load(version string) (*Template, error) {
+	data, err := templates.ReadFile("templates/" + version + ".tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("unknown prompt version %q (available: %s)", version, strings.Join(Versions(), ", "))
+	}
+	return parse(version, string(data))
+}
+
+// LoadFile はファイルからプロンプトを読み込みます。バージョンは拡張子を除いたファイル名になります。
+func LoadFile(filename string) (*Template, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	version := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return parse(version, string(data))
+}
+
+// Versions は埋め込まれたプロンプトのバージョンの一覧を返します。
+func Versions() []string {
+	entries, _ := fs.ReadDir(templates, "templates")
+
+	var versions []string
+	for _, entry := range entries {
+		versions = append(versions, strings.TrimSuffix(entry.Name(), ".tmpl"))
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func parse(version string, text string) (*Template, error) {
+	tmpl, err := template.New(version).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("prompt %s: %w", version, err)
+	}
+	for _, name := range []string{"system", "user"} {
+		if tmpl.Lookup(name) == nil {
+			return nil, fmt.Errorf("prompt %s: missing %q block", version, name)
+		}
+	}
+	return &Template{Version: version, tmpl: tmpl}, nil
+}
+
+// Render はシステムメッセージとユーザーメッセージを生成します。
+func (t *Template) Render(data Data) (system string, user string, err error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.ExecuteTemplate(&buf, "system", data); err != nil {
+		return "", "", err
+	}
+	system = buf.String()
+
+	buf.Reset()
+	if err := t.tmpl.ExecuteTemplate(&buf, "user", data); err != nil {
+		return "", "", err
+	}
+	user = buf.String()
+
+	return system, user, nil
+}
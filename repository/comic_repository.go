@@ -3,14 +3,15 @@
 package repository
 
 import (
+	"comic-summaries/config"
 	"comic-summaries/entity"
 	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
-	"os"
 )
 
 type IComicRepository interface {
@@ -18,30 +19,73 @@ type IComicRepository interface {
 	FindAll(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error)
 	FindByTitle(ctx context.Context, title string) ([]*entity.Comic, error)
 	GetTotalCount(ctx context.Context) (int, error)
+	Save(ctx context.Context, comic *entity.Comic) error
+
+	// 以下は公開中(Statusが空またはpublished)の漫画だけを対象にします。
+	FindAllPublished(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error)
+	FindPublishedByTitle(ctx context.Context, title string) ([]*entity.Comic, error)
+	GetPublishedCount(ctx context.Context) (int, error)
+	// FindByStatus は指定したレビュー状態の漫画を返します。in_reviewには、再生成した要約が承認を待っている公開中の漫画も含みます。
+	FindByStatus(ctx context.Context, status string) ([]*entity.Comic, error)
+	// Ping は保存先に接続でき、使える状態であるかを確認します。
+	Ping(ctx context.Context) error
 }
 
 type comicRepository struct {
 	db *dynamodb.DynamoDB
 }
 
-func NewComicRepository() IComicRepository {
-	dynamodbEndpoint := os.Getenv("DYNAMODB_ENDPOINT")
-
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region:      aws.String(os.Getenv("AWS_REGION")),
-		Endpoint:    aws.String(dynamodbEndpoint),
-		Credentials: credentials.NewStaticCredentials(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), ""),
-	}))
-
-	db := dynamodb.New(sess)
+func NewComicRepository(db *dynamodb.DynamoDB) IComicRepository {
 	return &comicRepository{
 		db: db,
 	}
 }
 
+// NewDynamoDB は設定に従ってDynamoDBクライアントを生成します。アクセスキーを指定しない場合はAWS SDKの既定の認証情報を使います。
+func NewDynamoDB(cfg config.AWS) *dynamodb.DynamoDB {
+	awsConfig := &aws.Config{
+		Region: aws.String(cfg.Region),
+	}
+	if cfg.DynamoDBEndpoint != "" {
+		awsConfig.Endpoint = aws.String(cfg.DynamoDBEndpoint)
+	}
+	if cfg.AccessKeyID != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	}
+
+	return dynamodb.New(session.Must(session.NewSession(awsConfig)))
+}
+
+// CloseDynamoDB はDynamoDBクライアントが保持している待機中の接続を閉じます。サーバーの終了時に呼び出します。
+func CloseDynamoDB(db *dynamodb.DynamoDB) {
+	if client := db.Config.HTTPClient; client != nil {
+		client.CloseIdleConnections()
+	}
+}
+
+func (r *comicRepository) Ping(ctx context.Context) error {
+	return pingTable(ctx, r.db, "ComicSummaries")
+}
+
+// pingTable はDescribeTableでテーブルが読み書きできる状態であるかを確認します。項目を読まないため容量を消費しません。
+func pingTable(ctx context.Context, db *dynamodb.DynamoDB, tableName string) error {
+	out, err := db.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return err
+	}
+	// UPDATING中のテーブルも読み書きできる
+	switch status := aws.StringValue(out.Table.TableStatus); status {
+	case dynamodb.TableStatusActive, dynamodb.TableStatusUpdating:
+		return nil
+	default:
+		return fmt.Errorf("table %s is %s", tableName, status)
+	}
+}
+
 func (r *comicRepository) FindByID(ctx context.Context, id string) (*entity.Comic, error) {
 	input := &dynamodb.GetItemInput{
-		TableName: aws.String("ComicSummaries"),
+		TableName:              aws.String("ComicSummaries"),
+		ReturnConsumedCapacity: returnConsumedCapacity,
 		Key: map[string]*dynamodb.AttributeValue{
 			"ID": {
 				N: aws.String(id),
@@ -50,6 +94,7 @@ func (r *comicRepository) FindByID(ctx context.Context, id string) (*entity.Comi
 	}
 
 	result, err := r.db.GetItemWithContext(ctx, input)
+	addConsumedCapacity(ctx, result.ConsumedCapacity)
 	if err != nil {
 		return nil, err
 	}
@@ -68,12 +113,14 @@ func (r *comicRepository) FindByID(ctx context.Context, id string) (*entity.Comi
 
 func (r *comicRepository) FindAll(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
 	input := &dynamodb.ScanInput{
-		TableName:         aws.String("ComicSummaries"),
-		Limit:             aws.Int64(int64(limit)),
-		ExclusiveStartKey: lastEvaluatedKey,
+		TableName:              aws.String("ComicSummaries"),
+		ReturnConsumedCapacity: returnConsumedCapacity,
+		Limit:                  aws.Int64(int64(limit)),
+		ExclusiveStartKey:      lastEvaluatedKey,
 	}
 
 	result, err := r.db.ScanWithContext(ctx, input)
+	addConsumedCapacity(ctx, result.ConsumedCapacity)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -92,8 +139,9 @@ func (r *comicRepository) FindAll(ctx context.Context, limit int, lastEvaluatedK
 
 func (r *comicRepository) FindByTitle(ctx context.Context, title string) ([]*entity.Comic, error) {
 	input := &dynamodb.ScanInput{
-		TableName:        aws.String("ComicSummaries"),
-		FilterExpression: aws.String("contains(#title, :title)"),
+		TableName:              aws.String("ComicSummaries"),
+		ReturnConsumedCapacity: returnConsumedCapacity,
+		FilterExpression:       aws.String("contains(#title, :title)"),
 		ExpressionAttributeNames: map[string]*string{
 			"#title": aws.String("Title"),
 		},
@@ -105,6 +153,7 @@ func (r *comicRepository) FindByTitle(ctx context.Context, title string) ([]*ent
 	}
 
 	result, err := r.db.ScanWithContext(ctx, input)
+	addConsumedCapacity(ctx, result.ConsumedCapacity)
 	if err != nil {
 		return nil, err
 	}
@@ -123,14 +172,191 @@ func (r *comicRepository) FindByTitle(ctx context.Context, title string) ([]*ent
 
 func (r *comicRepository) GetTotalCount(ctx context.Context) (int, error) {
 	input := &dynamodb.ScanInput{
-		TableName: aws.String("ComicSummaries"),
-		Select:    aws.String("COUNT"),
+		TableName:              aws.String("ComicSummaries"),
+		ReturnConsumedCapacity: returnConsumedCapacity,
+		Select:                 aws.String("COUNT"),
 	}
 
 	result, err := r.db.ScanWithContext(ctx, input)
+	addConsumedCapacity(ctx, result.ConsumedCapacity)
 	if err != nil {
 		return 0, err
 	}
 
 	return int(*result.Count), nil
 }
+
+func (r *comicRepository) Save(ctx context.Context, comic *entity.Comic) error {
+	item, err := dynamodbattribute.MarshalMap(comic)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:              aws.String("ComicSummaries"),
+		ReturnConsumedCapacity: returnConsumedCapacity,
+		Item:                   item,
+	})
+	addConsumedCapacity(ctx, result.ConsumedCapacity)
+	return err
+}
+
+// publishedFilter は公開中の漫画だけを残すフィルター式です。Statusのないデータも公開中とみなします。
+const publishedFilter = "(attribute_not_exists(#status) OR #status = :published)"
+
+// withPublishedFilter はScanの条件に公開中の漫画だけを残すフィルターを追加します。
+func withPublishedFilter(input *dynamodb.ScanInput) *dynamodb.ScanInput {
+	if input.FilterExpression == nil {
+		input.FilterExpression = aws.String(publishedFilter)
+	} else {
+		input.FilterExpression = aws.String(*input.FilterExpression + " AND " + publishedFilter)
+	}
+	if input.ExpressionAttributeNames == nil {
+		input.ExpressionAttributeNames = map[string]*string{}
+	}
+	input.ExpressionAttributeNames["#status"] = aws.String("Status")
+	if input.ExpressionAttributeValues == nil {
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{}
+	}
+	input.ExpressionAttributeValues[":published"] = &dynamodb.AttributeValue{S: aws.String(entity.StatusPublished)}
+	return input
+}
+
+// FindAllPublished は公開中の漫画をlimit件まで返します。
+// DynamoDBはLimitの件数を読んでからフィルターを適用するため、下書きなどが混ざるとページが欠けます。
+// limit件集まるかテーブルの最後に達するまで、足りない件数をLimitにしてスキャンを続けます。
+func (r *comicRepository) FindAllPublished(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
+	comics := make([]*entity.Comic, 0, limit)
+	for {
+		input := withPublishedFilter(&dynamodb.ScanInput{
+			TableName:              aws.String("ComicSummaries"),
+			ReturnConsumedCapacity: returnConsumedCapacity,
+			Limit:                  aws.Int64(int64(limit - len(comics))),
+			ExclusiveStartKey:      lastEvaluatedKey,
+		})
+
+		result, err := r.db.ScanWithContext(ctx, input)
+		addConsumedCapacity(ctx, result.ConsumedCapacity)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		found, err := unmarshalComics(result.Items)
+		if err != nil {
+			return nil, nil, err
+		}
+		comics = append(comics, found...)
+		lastEvaluatedKey = result.LastEvaluatedKey
+		// 読んだ件数以上は返らないため、limit件を超えることはない
+		if len(comics) >= limit || lastEvaluatedKey == nil {
+			return comics, lastEvaluatedKey, nil
+		}
+	}
+}
+
+func (r *comicRepository) FindPublishedByTitle(ctx context.Context, title string) ([]*entity.Comic, error) {
+	input := withPublishedFilter(&dynamodb.ScanInput{
+		TableName:              aws.String("ComicSummaries"),
+		ReturnConsumedCapacity: returnConsumedCapacity,
+		FilterExpression:       aws.String("contains(#title, :title)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#title": aws.String("Title"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":title": {
+				S: aws.String(title),
+			},
+		},
+	})
+
+	result, err := r.db.ScanWithContext(ctx, input)
+	addConsumedCapacity(ctx, result.ConsumedCapacity)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalComics(result.Items)
+}
+
+func (r *comicRepository) GetPublishedCount(ctx context.Context) (int, error) {
+	input := withPublishedFilter(&dynamodb.ScanInput{
+		TableName:              aws.String("ComicSummaries"),
+		ReturnConsumedCapacity: returnConsumedCapacity,
+		Select:                 aws.String("COUNT"),
+	})
+
+	// フィルター付きのCOUNTは1MBごとに区切られるため、最後のページまで合計します。
+	count := 0
+	err := r.db.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		addConsumedCapacity(ctx, page.ConsumedCapacity)
+		count += int(aws.Int64Value(page.Count))
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *comicRepository) FindByStatus(ctx context.Context, status string) ([]*entity.Comic, error) {
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String("ComicSummaries"),
+		ReturnConsumedCapacity: returnConsumedCapacity,
+		FilterExpression:       aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("Status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {
+				S: aws.String(status),
+			},
+		},
+	}
+	if status == entity.StatusPublished {
+		input = withPublishedFilter(&dynamodb.ScanInput{TableName: aws.String("ComicSummaries"), ReturnConsumedCapacity: returnConsumedCapacity})
+	}
+	if status == entity.StatusInReview {
+		// 公開中のまま要約だけが承認を待っている漫画も読み込み、下でAwaitingReviewで絞り込む
+		input.FilterExpression = aws.String("#status = :status OR attribute_exists(#pending)")
+		input.ExpressionAttributeNames["#pending"] = aws.String("Pending")
+	}
+
+	comics := make([]*entity.Comic, 0)
+	var unmarshalErr error
+	err := r.db.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		addConsumedCapacity(ctx, page.ConsumedCapacity)
+		var found []*entity.Comic
+		found, unmarshalErr = unmarshalComics(page.Items)
+		comics = append(comics, found...)
+		return unmarshalErr == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	if status == entity.StatusInReview {
+		awaiting := make([]*entity.Comic, 0, len(comics))
+		for _, comic := range comics {
+			if comic.AwaitingReview() {
+				awaiting = append(awaiting, comic)
+			}
+		}
+		comics = awaiting
+	}
+	entity.SortComicsByID(comics)
+	return comics, nil
+}
+
+// unmarshalComics はScanで取得したアイテムを漫画データに変換します。
+func unmarshalComics(items []map[string]*dynamodb.AttributeValue) ([]*entity.Comic, error) {
+	comics := make([]*entity.Comic, 0, len(items))
+	for _, item := range items {
+		comic := new(entity.Comic)
+		if err := dynamodbattribute.UnmarshalMap(item, comic); err != nil {
+			return nil, err
+		}
+		comics = append(comics, comic)
+	}
+	return comics, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"comic-summaries/config"
+	"comic-summaries/entity"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// scanServer はDynamoDBのScanを真似るサーバーです。Limitの件数を読んでから公開中の漫画だけを返し、
+// 読み残しがあればLastEvaluatedKeyを返します。statusesはIDが1から順の漫画のStatusです。
+func scanServer(t *testing.T, statuses []string) (*comicRepository, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "DynamoDB_20120810.Scan" {
+			t.Errorf("unexpected request %s", r.Header.Get("X-Amz-Target"))
+			http.Error(w, "unsupported", http.StatusBadRequest)
+			return
+		}
+		requests++
+		var input struct {
+			Limit             int
+			ExclusiveStartKey map[string]map[string]string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Error(err)
+		}
+		start := 0
+		if key, ok := input.ExclusiveStartKey["ID"]; ok {
+			start, _ = strconv.Atoi(key["N"])
+		}
+
+		items := []map[string]interface{}{}
+		end := start
+		for end < len(statuses) && end-start < input.Limit {
+			end++
+			if status := statuses[end-1]; status == "" || status == entity.StatusPublished {
+				items = append(items, map[string]interface{}{
+					"ID":    map[string]string{"N": strconv.Itoa(end)},
+					"Title": map[string]string{"S": "漫画" + strconv.Itoa(end)},
+				})
+			}
+		}
+		out := map[string]interface{}{"Items": items, "Count": len(items), "ScannedCount": end - start}
+		if end < len(statuses) {
+			out["LastEvaluatedKey"] = map[string]interface{}{"ID": map[string]string{"N": strconv.Itoa(end)}}
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(server.Close)
+
+	db := NewDynamoDB(config.AWS{Region: "us-east-1", DynamoDBEndpoint: server.URL, AccessKeyID: "test", SecretAccessKey: "test"})
+	return &comicRepository{db: db}, &requests
+}
+
+func ids(comics []*entity.Comic) []int {
+	result := make([]int, 0, len(comics))
+	for _, comic := range comics {
+		result = append(result, comic.ID)
+	}
+	return result
+}
+
+func TestFindAllPublishedFillsPagesPastDrafts(t *testing.T) {
+	const draft, published = entity.StatusDraft, entity.StatusPublished
+	// 先頭の3件を読んでも公開中の漫画は1件しかない
+	repo, requests := scanServer(t, []string{draft, published, draft, "", draft, draft, published, published})
+	ctx := context.Background()
+
+	comics, key, err := repo.FindAllPublished(ctx, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(comics); len(got) != 3 || got[0] != 2 || got[1] != 4 || got[2] != 7 {
+		t.Fatalf("first page = %v, want [2 4 7]", got)
+	}
+	if key == nil || *key["ID"].N != "7" {
+		t.Fatalf("LastEvaluatedKey = %v, want the last returned comic", key)
+	}
+	if *requests < 2 {
+		t.Errorf("scanned %d times, want to keep scanning past the drafts", *requests)
+	}
+
+	comics, key, err = repo.FindAllPublished(ctx, 3, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(comics); len(got) != 1 || got[0] != 8 || key != nil {
+		t.Errorf("last page = %v with key %v, want [8] and no key", got, key)
+	}
+}
+
+func TestFindAllPublishedWithOnlyDrafts(t *testing.T) {
+	repo, _ := scanServer(t, []string{entity.StatusDraft, entity.StatusInReview, entity.StatusRejected})
+
+	comics, key, err := repo.FindAllPublished(context.Background(), 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comics) != 0 || key != nil {
+		t.Errorf("FindAllPublished = %v with key %v, want an empty last page", ids(comics), key)
+	}
+}
@@ -0,0 +1,147 @@
+// repository/instrumented_comic_repository.go
+
+package repository
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/metrics"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"sync"
+	"time"
+)
+
+// returnConsumedCapacity はDynamoDBに消費したキャパシティユニットを返させる指定です。
+var returnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+
+type capacityKey struct{}
+
+// capacityRecorder はリポジトリの1回の操作で消費したキャパシティユニットの合計です。ScanPagesのように複数回呼び出す操作は合計します。
+type capacityRecorder struct {
+	mu    sync.Mutex
+	units float64
+}
+
+// addConsumedCapacity はctxに記録先があれば消費したキャパシティユニットを足します。
+func addConsumedCapacity(ctx context.Context, consumed *dynamodb.ConsumedCapacity) {
+	recorder, ok := ctx.Value(capacityKey{}).(*capacityRecorder)
+	if !ok || consumed == nil {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.units += aws.Float64Value(consumed.CapacityUnits)
+}
+
+// instrumentedComicRepository は操作ごとの回数、所要時間、消費したキャパシティユニットを記録するIComicRepositoryです。
+type instrumentedComicRepository struct {
+	repo     IComicRepository
+	backend  string
+	calls    *metrics.CounterVec
+	duration *metrics.HistogramVec
+	capacity *metrics.CounterVec
+}
+
+// NewInstrumentedComicRepository はrepoの操作をregistryのメトリクスに記録するIComicRepositoryを生成します。
+// backendは "dynamodb" や "memory" のような保存先の名前で、メトリクスのラベルに使います。
+func NewInstrumentedComicRepository(repo IComicRepository, backend string, registry *metrics.Registry) IComicRepository {
+	return &instrumentedComicRepository{
+		repo:    repo,
+		backend: backend,
+		calls: registry.NewCounterVec("comic_repository_operations_total",
+			"Comic repository operations by result (ok or error).", "backend", "operation", "result"),
+		duration: registry.NewHistogramVec("comic_repository_operation_duration_seconds",
+			"Latency of comic repository operations.", nil, "backend", "operation"),
+		capacity: registry.NewCounterVec("comic_repository_consumed_capacity_units_total",
+			"DynamoDB capacity units consumed by comic repository operations.", "backend", "operation"),
+	}
+}
+
+// observe はoperationの開始時に呼び出し、返した関数を終了時にエラーと共に呼び出します。
+func (r *instrumentedComicRepository) observe(ctx context.Context, operation string) (context.Context, func(err error)) {
+	recorder := &capacityRecorder{}
+	ctx = context.WithValue(ctx, capacityKey{}, recorder)
+	start := time.Now()
+	return ctx, func(err error) {
+		r.duration.With(r.backend, operation).Observe(time.Since(start).Seconds())
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		r.calls.With(r.backend, operation, result).Inc()
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		r.capacity.With(r.backend, operation).Add(recorder.units)
+	}
+}
+
+func (r *instrumentedComicRepository) FindByID(ctx context.Context, id string) (*entity.Comic, error) {
+	ctx, done := r.observe(ctx, "FindByID")
+	comic, err := r.repo.FindByID(ctx, id)
+	done(err)
+	return comic, err
+}
+
+func (r *instrumentedComicRepository) FindAll(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
+	ctx, done := r.observe(ctx, "FindAll")
+	comics, key, err := r.repo.FindAll(ctx, limit, lastEvaluatedKey)
+	done(err)
+	return comics, key, err
+}
+
+func (r *instrumentedComicRepository) FindByTitle(ctx context.Context, title string) ([]*entity.Comic, error) {
+	ctx, done := r.observe(ctx, "FindByTitle")
+	comics, err := r.repo.FindByTitle(ctx, title)
+	done(err)
+	return comics, err
+}
+
+func (r *instrumentedComicRepository) GetTotalCount(ctx context.Context) (int, error) {
+	ctx, done := r.observe(ctx, "GetTotalCount")
+	count, err := r.repo.GetTotalCount(ctx)
+	done(err)
+	return count, err
+}
+
+func (r *instrumentedComicRepository) Save(ctx context.Context, comic *entity.Comic) error {
+	ctx, done := r.observe(ctx, "Save")
+	err := r.repo.Save(ctx, comic)
+	done(err)
+	return err
+}
+
+func (r *instrumentedComicRepository) FindAllPublished(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
+	ctx, done := r.observe(ctx, "FindAllPublished")
+	comics, key, err := r.repo.FindAllPublished(ctx, limit, lastEvaluatedKey)
+	done(err)
+	return comics, key, err
+}
+
+func (r *instrumentedComicRepository) FindPublishedByTitle(ctx context.Context, title string) ([]*entity.Comic, error) {
+	ctx, done := r.observe(ctx, "FindPublishedByTitle")
+	comics, err := r.repo.FindPublishedByTitle(ctx, title)
+	done(err)
+	return comics, err
+}
+
+func (r *instrumentedComicRepository) GetPublishedCount(ctx context.Context) (int, error) {
+	ctx, done := r.observe(ctx, "GetPublishedCount")
+	count, err := r.repo.GetPublishedCount(ctx)
+	done(err)
+	return count, err
+}
+
+func (r *instrumentedComicRepository) FindByStatus(ctx context.Context, status string) ([]*entity.Comic, error) {
+	ctx, done := r.observe(ctx, "FindByStatus")
+	comics, err := r.repo.FindByStatus(ctx, status)
+	done(err)
+	return comics, err
+}
+
+func (r *instrumentedComicRepository) Ping(ctx context.Context) error {
+	ctx, done := r.observe(ctx, "Ping")
+	err := r.repo.Ping(ctx)
+	done(err)
+	return err
+}
@@ -0,0 +1,152 @@
+// repository/memory_comic_repository.go
+
+package repository
+
+import (
+	"comic-summaries/entity"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// memoryComicRepository はDynamoDBを使わずに動かすためのメモリ上のリポジトリです。
+// ページングのキーはDynamoDBと同じく最後に返したアイテムのIDを使います。
+type memoryComicRepository struct {
+	mu     sync.RWMutex
+	comics map[int]entity.Comic
+}
+
+// NewMemoryComicRepository はcomicsを初期データとするメモリ上のリポジトリを生成します。
+func NewMemoryComicRepository(comics []entity.Comic) IComicRepository {
+	r := &memoryComicRepository{
+		comics: make(map[int]entity.Comic, len(comics)),
+	}
+	for _, comic := range comics {
+		r.comics[comic.ID] = comic
+	}
+	return r
+}
+
+func (r *memoryComicRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *memoryComicRepository) FindByID(ctx context.Context, id string) (*entity.Comic, error) {
+	comicID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	comic, ok := r.comics[comicID]
+	if !ok {
+		return nil, nil
+	}
+	return &comic, nil
+}
+
+func (r *memoryComicRepository) FindAll(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
+	return r.page(limit, lastEvaluatedKey, r.sorted())
+}
+
+func (r *memoryComicRepository) FindAllPublished(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
+	return r.page(limit, lastEvaluatedKey, r.filter(func(c *entity.Comic) bool { return c.Published() }))
+}
+
+// page はID順に並んだsortedから、lastEvaluatedKeyの次の1ページを返します。
+func (r *memoryComicRepository) page(limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue, sorted []*entity.Comic) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
+	after := 0
+	if key, ok := lastEvaluatedKey["ID"]; ok && key.N != nil {
+		var err error
+		if after, err = strconv.Atoi(*key.N); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	comics := make([]*entity.Comic, 0)
+	for _, comic := range sorted {
+		if comic.ID <= after {
+			continue
+		}
+		comics = append(comics, comic)
+		if len(comics) == limit {
+			break
+		}
+	}
+
+	var nextKey map[string]*dynamodb.AttributeValue
+	if len(comics) > 0 && len(comics) == limit && comics[len(comics)-1].ID != sorted[len(sorted)-1].ID {
+		nextKey = map[string]*dynamodb.AttributeValue{
+			"ID": {N: aws.String(strconv.Itoa(comics[len(comics)-1].ID))},
+		}
+	}
+	return comics, nextKey, nil
+}
+
+func (r *memoryComicRepository) FindByTitle(ctx context.Context, title string) ([]*entity.Comic, error) {
+	return r.filter(func(c *entity.Comic) bool { return strings.Contains(c.Title, title) }), nil
+}
+
+func (r *memoryComicRepository) FindPublishedByTitle(ctx context.Context, title string) ([]*entity.Comic, error) {
+	return r.filter(func(c *entity.Comic) bool { return c.Published() && strings.Contains(c.Title, title) }), nil
+}
+
+func (r *memoryComicRepository) GetPublishedCount(ctx context.Context) (int, error) {
+	return len(r.filter(func(c *entity.Comic) bool { return c.Published() })), nil
+}
+
+func (r *memoryComicRepository) FindByStatus(ctx context.Context, status string) ([]*entity.Comic, error) {
+	if status == entity.StatusPublished {
+		return r.filter(func(c *entity.Comic) bool { return c.Published() }), nil
+	}
+	if status == entity.StatusInReview {
+		return r.filter(func(c *entity.Comic) bool { return c.AwaitingReview() }), nil
+	}
+	return r.filter(func(c *entity.Comic) bool { return c.Status == status }), nil
+}
+
+func (r *memoryComicRepository) GetTotalCount(ctx context.Context) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.comics), nil
+}
+
+func (r *memoryComicRepository) Save(ctx context.Context, comic *entity.Comic) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.comics[comic.ID] = *comic
+	return nil
+}
+
+// filter はmatchに一致する漫画のコピーをID順に返します。
+func (r *memoryComicRepository) filter(match func(c *entity.Comic) bool) []*entity.Comic {
+	comics := make([]*entity.Comic, 0)
+	for _, comic := range r.sorted() {
+		if match(comic) {
+			comics = append(comics, comic)
+		}
+	}
+	return comics
+}
+
+// sorted は全件のコピーをID順に返します。
+func (r *memoryComicRepository) sorted() []*entity.Comic {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	comics := make([]*entity.Comic, 0, len(r.comics))
+	for _, comic := range r.comics {
+		comic := comic
+		comics = append(comics, &comic)
+	}
+	sort.Slice(comics, func(i, j int) bool {
+		return comics[i].ID < comics[j].ID
+	})
+	return comics
+}
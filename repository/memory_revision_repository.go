@@ -0,0 +1,57 @@
+// repository/memory_revision_repository.go
+
+package repository
+
+import (
+	"comic-summaries/entity"
+	"context"
+	"sort"
+	"sync"
+)
+
+// memoryRevisionRepository はメモリ上のリビジョンのリポジトリです。
+type memoryRevisionRepository struct {
+	mu        sync.RWMutex
+	revisions map[int]map[int]entity.Revision
+}
+
+func NewMemoryRevisionRepository() IRevisionRepository {
+	return &memoryRevisionRepository{
+		revisions: make(map[int]map[int]entity.Revision),
+	}
+}
+
+func (r *memoryRevisionRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *memoryRevisionRepository) Create(ctx context.Context, revision *entity.Revision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byNumber, ok := r.revisions[revision.ComicID]
+	if !ok {
+		byNumber = make(map[int]entity.Revision)
+		r.revisions[revision.ComicID] = byNumber
+	}
+	if _, exists := byNumber[revision.Revision]; exists {
+		return ErrRevisionExists
+	}
+	byNumber[revision.Revision] = *revision
+	return nil
+}
+
+func (r *memoryRevisionRepository) FindByComicID(ctx context.Context, comicID int) ([]*entity.Revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revisions := make([]*entity.Revision, 0, len(r.revisions[comicID]))
+	for _, revision := range r.revisions[comicID] {
+		revision := revision
+		revisions = append(revisions, &revision)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	return revisions, nil
+}
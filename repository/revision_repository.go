@@ -0,0 +1,125 @@
+// repository/revision_repository.go
+
+package repository
+
+import (
+	"comic-summaries/entity"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"strconv"
+)
+
+// ErrRevisionExists は同じ番号のリビジョンがすでに保存されていることを表します。
+var ErrRevisionExists = errors.New("revision already exists")
+
+type IRevisionRepository interface {
+	// Create はリビジョンを保存します。同じ番号のリビジョンがあればErrRevisionExistsを返し、上書きしません。
+	Create(ctx context.Context, revision *entity.Revision) error
+	// FindByComicID は漫画のリビジョンを番号の昇順で返します。
+	FindByComicID(ctx context.Context, comicID int) ([]*entity.Revision, error)
+	// Ping は保存先に接続でき、使える状態であるかを確認します。
+	Ping(ctx context.Context) error
+}
+
+// revisionTableName はリビジョンを保存するテーブルです。ComicID(N)をパーティションキー、Revision(N)をソートキーとします。
+const revisionTableName = "ComicSummaryRevisions"
+
+type revisionRepository struct {
+	db *dynamodb.DynamoDB
+}
+
+func NewRevisionRepository(db *dynamodb.DynamoDB) IRevisionRepository {
+	return &revisionRepository{
+		db: db,
+	}
+}
+
+// EnsureRevisionTable はリビジョンのテーブルがなければ作成し、利用可能になるまで待ちます。
+func EnsureRevisionTable(ctx context.Context, db *dynamodb.DynamoDB) error {
+	_, err := db.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(revisionTableName)})
+	if err == nil {
+		return nil
+	}
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+		return err
+	}
+
+	_, err = db.CreateTableWithContext(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(revisionTableName),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("ComicID"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeN)},
+			{AttributeName: aws.String("Revision"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeN)},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("ComicID"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			{AttributeName: aws.String("Revision"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(revisionTableName)})
+}
+
+func (r *revisionRepository) Ping(ctx context.Context) error {
+	return pingTable(ctx, r.db, revisionTableName)
+}
+
+func (r *revisionRepository) Create(ctx context.Context, revision *entity.Revision) error {
+	item, err := dynamodbattribute.MarshalMap(revision)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(revisionTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(Revision)"),
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrRevisionExists
+	}
+	return err
+}
+
+func (r *revisionRepository) FindByComicID(ctx context.Context, comicID int) ([]*entity.Revision, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(revisionTableName),
+		KeyConditionExpression: aws.String("ComicID = :id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id": {
+				N: aws.String(strconv.Itoa(comicID)),
+			},
+		},
+		ScanIndexForward: aws.Bool(true),
+	}
+
+	revisions := make([]*entity.Revision, 0)
+	var unmarshalErr error
+	err := r.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			revision := new(entity.Revision)
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(item, revision); unmarshalErr != nil {
+				return false
+			}
+			revisions = append(revisions, revision)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,40 @@
+package scraper
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	Register(kmangaParser{})
+}
+
+// kmangaParser はめちゃコミック(comic.k-manga.jp)の検索・ランキングページを読み取ります。
+type kmangaParser struct{}
+
+func (kmangaParser) Hosts() []string {
+	return []string{"comic.k-manga.jp"}
+}
+
+func (kmangaParser) Parse(doc *goquery.Document, pageURL *url.URL) ([]Item, error) {
+	items := make([]Item, 0)
+	var parseErr error
+	doc.Find(".book-list--item").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		src, exists := s.Find(".book-list--img").Attr("src")
+		if !exists {
+			return true
+		}
+		imageURL, err := pageURL.Parse(strings.TrimSpace(src))
+		if err != nil {
+			parseErr = err
+			return false
+		}
+		items = append(items, Item{
+			Title:    strings.TrimSpace(s.Find(".book-list--title").Text()),
+			ImageURL: imageURL.String(),
+		})
+		return true
+	})
+	return items, parseErr
+}
@@ -0,0 +1,137 @@
+package scraper
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robots はrobots.txtのうち、このクローラーに適用されるグループの規則です。
+type robots struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+// robotsGroup はUser-agentの行から次のUser-agentの並びまでの1グループです。
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// parseRobots はrobots.txtを読み、userAgentに適用されるグループを返します。
+// 名前が一致するグループがなければ "*" のグループを使います。
+func parseRobots(r io.Reader, userAgent string) (*robots, error) {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			// 空のDisallowは全て許可する意味のため規則を追加しない
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	token := strings.ToLower(userAgent)
+	if i := strings.Index(token, "/"); i >= 0 {
+		token = token[:i]
+	}
+	var fallback *robotsGroup
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if agent == "*" {
+				if fallback == nil {
+					fallback = group
+				}
+			} else if agent == token {
+				return &robots{rules: group.rules, crawlDelay: group.crawlDelay}, nil
+			}
+		}
+	}
+	if fallback != nil {
+		return &robots{rules: fallback.rules, crawlDelay: fallback.crawlDelay}, nil
+	}
+	return &robots{}, nil
+}
+
+// robotsPattern はrobots.txtのパスを正規表現に変換します。* は任意の文字列、末尾の $ はパスの終わりに一致します。
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+
+	parts := strings.Split(path, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed はパスへのアクセスが許可されているかを返します。
+// 一致する規則のうち最も長いものに従い、同じ長さならAllowを優先します。
+func (r *robots) allowed(path string) bool {
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || (rule.length == longest && rule.allow) {
+			allowed = rule.allow
+			longest = rule.length
+		}
+	}
+	return allowed
+}
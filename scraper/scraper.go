@@ -0,0 +1,240 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUserAgent はリクエストとrobots.txtの判定に使用するUser-Agentです。
+const DefaultUserAgent = "comic-summaries-bot/1.0"
+
+// ErrNoParser はURLのサイトに対応するParserが登録されていないことを表します。
+var ErrNoParser = errors.New("no parser for site")
+
+// ErrDisallowed はrobots.txtでクロールが禁止されているURLであることを表します。
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// StatusError は200以外のステータスコードが返されたことを表します。
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s: status code %d", e.URL, e.StatusCode)
+}
+
+// Item はランキングページに掲載された1作品です。
+type Item struct {
+	Title    string
+	ImageURL string
+}
+
+// Parser はサイトごとのランキングページの読み取り方です。
+// 新しいサイトに対応する場合はParserを実装したファイルを追加し、initでRegisterします。
+type Parser interface {
+	// Hosts は対応するホスト名です。
+	Hosts() []string
+	// Parse はページから作品を掲載順に読み取ります。画像のURLはpageURLを基準に絶対URLにします。
+	Parse(doc *goquery.Document, pageURL *url.URL) ([]Item, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Parser{}
+)
+
+// Register はParserを対応するホスト名で登録します。同じホスト名は後から登録したものが優先されます。
+func Register(p Parser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, host := range p.Hosts() {
+		registry[strings.ToLower(host)] = p
+	}
+}
+
+// ParserFor はURLのホストに対応するParserを返します。
+func ParserFor(u *url.URL) (Parser, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[strings.ToLower(u.Hostname())]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoParser, u.Hostname())
+	}
+	return p, nil
+}
+
+// Scraper はランキングページから作品を取得します。
+type Scraper interface {
+	// Scrape はpageURLから最大limit件の作品を取得します。掲載数がlimitより少ない場合は掲載されている分だけ返します。
+	Scrape(ctx context.Context, pageURL string, limit int) ([]Item, error)
+}
+
+// Options はScraperの設定です。0の項目は既定値になります。
+type Options struct {
+	// Client はリクエストに使用するHTTPクライアントです。既定値はタイムアウト30秒のクライアントです。
+	Client *http.Client
+	// UserAgent はリクエストに付けるUser-Agentです。既定値はDefaultUserAgentです。
+	UserAgent string
+	// Delay は同じホストへのリクエストの最小間隔です。robots.txtのCrawl-delayの方が長い場合はそちらを使います。
+	Delay time.Duration
+	// IgnoreRobots がtrueの場合はrobots.txtを確認しません。
+	IgnoreRobots bool
+}
+
+type scraper struct {
+	client       *http.Client
+	userAgent    string
+	delay        time.Duration
+	ignoreRobots bool
+
+	mu     sync.Mutex
+	robots map[string]*robots
+	last   map[string]time.Time
+}
+
+// New は新しいScraperを生成します。
+func New(opts Options) Scraper {
+	s := &scraper{
+		client:       opts.Client,
+		userAgent:    opts.UserAgent,
+		delay:        opts.Delay,
+		ignoreRobots: opts.IgnoreRobots,
+		robots:       map[string]*robots{},
+		last:         map[string]time.Time{},
+	}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if s.userAgent == "" {
+		s.userAgent = DefaultUserAgent
+	}
+	return s
+}
+
+func (s *scraper) Scrape(ctx context.Context, pageURL string, limit int) ([]Item, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	parser, err := ParserFor(u)
+	if err != nil {
+		return nil, err
+	}
+
+	delay := s.delay
+	if !s.ignoreRobots {
+		rules, err := s.robotsFor(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		if !rules.allowed(u.RequestURI()) {
+			return nil, fmt.Errorf("%w: %s", ErrDisallowed, pageURL)
+		}
+		if rules.crawlDelay > delay {
+			delay = rules.crawlDelay
+		}
+	}
+
+	res, err := s.get(ctx, u, delay)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: pageURL, StatusCode: res.StatusCode}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", pageURL, err)
+	}
+	items, err := parser.Parse(doc, u)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", pageURL, err)
+	}
+
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// get はホストごとの間隔を空けてからGETリクエストを送ります。
+func (s *scraper) get(ctx context.Context, u *url.URL, delay time.Duration) (*http.Response, error) {
+	if err := s.wait(ctx, u.Host, delay); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	return s.client.Do(req)
+}
+
+// wait は同じホストへの前回のリクエストからdelayが経過するまで待ちます。
+func (s *scraper) wait(ctx context.Context, host string, delay time.Duration) error {
+	s.mu.Lock()
+	next := s.last[host].Add(delay)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	// 待っている間に他のリクエストが同じ時刻を使わないよう、先に予約する
+	s.last[host] = next
+	s.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// robotsFor はホストのrobots.txtを取得して解析します。結果はホストごとに保持します。
+func (s *scraper) robotsFor(ctx context.Context, u *url.URL) (*robots, error) {
+	s.mu.Lock()
+	rules, ok := s.robots[u.Host]
+	s.mu.Unlock()
+	if ok {
+		return rules, nil
+	}
+
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	res, err := s.get(ctx, robotsURL, s.delay)
+	if err != nil {
+		return nil, fmt.Errorf("fetch robots.txt: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		rules, err = parseRobots(res.Body, s.userAgent)
+		if err != nil {
+			return nil, fmt.Errorf("read robots.txt: %w", err)
+		}
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		// robots.txtがない場合は全て許可されているとみなす
+		rules = &robots{}
+	default:
+		return nil, &StatusError{URL: robotsURL.String(), StatusCode: res.StatusCode}
+	}
+
+	s.mu.Lock()
+	s.robots[u.Host] = rules
+	s.mu.Unlock()
+	return rules, nil
+}
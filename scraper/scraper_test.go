@@ -0,0 +1,299 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// newSiteServer はどのホスト宛てのリクエストもhandlerで受けるサーバーとHTTPクライアントを返します。
+// 実際のホスト名のままParserForでParserを選べるよう、接続先だけをテスト用のサーバーに差し替えます。
+func newSiteServer(t *testing.T, handler http.Handler) *http.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	addr := server.Listener.Addr().String()
+	dialer := &net.Dialer{}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+func TestKmangaGolden(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "kmanga_ranking.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotUserAgent atomic.Value
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /member/\n"))
+	})
+	mux.HandleFunc("/ranking/", func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent.Store(r.UserAgent())
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(fixture)
+	})
+	s := New(Options{Client: newSiteServer(t, mux)})
+
+	items, err := s.Scrape(context.Background(), "http://comic.k-manga.jp/ranking/", 0)
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if ua, _ := gotUserAgent.Load().(string); ua != DefaultUserAgent {
+		t.Errorf("User-Agent = %q, want %q", ua, DefaultUserAgent)
+	}
+
+	golden := filepath.Join("testdata", "kmanga_ranking.golden.json")
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(items); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.Bytes()
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Scrape result differs from %s (run with -update to rewrite):\n%s", golden, got)
+	}
+
+	limited, err := s.Scrape(context.Background(), "http://comic.k-manga.jp/ranking/", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited) != 2 || limited[0].Title != "ワンピース" {
+		t.Errorf("Scrape with limit 2 = %+v", limited)
+	}
+}
+
+func TestScrapeRespectsRobots(t *testing.T) {
+	var robotsRequests, pageRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		robotsRequests.Add(1)
+		w.Write([]byte("User-agent: comic-summaries-bot\nDisallow: /ranking/private\n"))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		pageRequests.Add(1)
+		w.Write([]byte(`<li class="book-list--item"><img class="book-list--img" src="/a.jpg"><p class="book-list--title">A</p></li>`))
+	})
+	s := New(Options{Client: newSiteServer(t, mux)})
+
+	_, err := s.Scrape(context.Background(), "http://comic.k-manga.jp/ranking/private?page=1", 0)
+	if !errors.Is(err, ErrDisallowed) {
+		t.Errorf("Scrape of a disallowed page: err = %v, want ErrDisallowed", err)
+	}
+	if _, err := s.Scrape(context.Background(), "http://comic.k-manga.jp/ranking/public", 0); err != nil {
+		t.Errorf("Scrape of an allowed page: %v", err)
+	}
+	if robotsRequests.Load() != 1 {
+		t.Errorf("robots.txt was fetched %d times, want once per host", robotsRequests.Load())
+	}
+	if pageRequests.Load() != 1 {
+		t.Errorf("pages were fetched %d times, want 1", pageRequests.Load())
+	}
+}
+
+func TestScrapeErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r) // robots.txtがなければ全て許可
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	s := New(Options{Client: newSiteServer(t, mux)})
+
+	var statusErr *StatusError
+	if _, err := s.Scrape(context.Background(), "http://comic.k-manga.jp/gone", 0); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusGone {
+		t.Errorf("err = %v, want a StatusError with 410", err)
+	}
+	if _, err := s.Scrape(context.Background(), "http://unknown.example.com/", 0); !errors.Is(err, ErrNoParser) {
+		t.Errorf("err = %v, want ErrNoParser", err)
+	}
+}
+
+func TestParseRobotsGroupSelection(t *testing.T) {
+	const txt = `# comment
+User-agent: otherbot
+Disallow: /
+
+User-agent: *
+Disallow: /private
+Crawl-delay: 1
+
+User-agent: Comic-Summaries-Bot   # 大文字小文字は区別しない
+User-agent: anotherbot
+Allow: /private/ok
+Disallow: /private
+Crawl-delay: 2.5
+`
+	tests := []struct {
+		userAgent string
+		delay     time.Duration
+		allowed   map[string]bool
+	}{
+		{"comic-summaries-bot/1.0", 2500 * time.Millisecond, map[string]bool{"/": true, "/private": false, "/private/ok": true}},
+		// 1つのグループに複数のUser-agentを書ける。名前は部分一致ではなく完全一致で比べる
+		{"anotherbot", 2500 * time.Millisecond, map[string]bool{"/private/ok/x": true}},
+		{"otherbot/2", 0, map[string]bool{"/": false, "/anything": false}},
+		{"bot", time.Second, map[string]bool{"/": true, "/private": false}},
+		// 名前の一致するグループがなければ * のグループを使う
+		{"somebot/1.0", time.Second, map[string]bool{"/": true, "/private": false, "/private/ok": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			rules, err := parseRobots(strings.NewReader(txt), tt.userAgent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rules.crawlDelay != tt.delay {
+				t.Errorf("crawlDelay = %s, want %s", rules.crawlDelay, tt.delay)
+			}
+			for path, want := range tt.allowed {
+				if got := rules.allowed(path); got != want {
+					t.Errorf("allowed(%q) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRobotsWithoutMatchingGroup(t *testing.T) {
+	rules, err := parseRobots(strings.NewReader("User-agent: otherbot\nDisallow: /\n"), DefaultUserAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rules.allowed("/anything") {
+		t.Error("a robots.txt without a matching group must allow everything")
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		path  string
+		want  bool
+	}{
+		{"no rules", "", "/a", true},
+		{"prefix match", "Disallow: /a", "/abc", false},
+		{"no match", "Disallow: /a", "/b", true},
+		{"empty disallow allows all", "Disallow:", "/a", true},
+		{"longest match wins", "Disallow: /a\nAllow: /a/b", "/a/b/c", true},
+		{"longest match wins regardless of order", "Allow: /a/b\nDisallow: /a/b/c", "/a/b/c/d", false},
+		{"allow wins a tie", "Disallow: /a\nAllow: /a", "/a", true},
+		{"wildcard", "Disallow: /*.pdf", "/docs/x.pdf", false},
+		{"wildcard without end anchor", "Disallow: /*.pdf", "/docs/x.pdf?download=1", false},
+		{"end anchor", "Disallow: /*.pdf$", "/docs/x.pdf?download=1", true},
+		{"end anchor matches the end", "Disallow: /*.pdf$", "/docs/x.pdf", false},
+		{"exact path with anchor", "Disallow: /$", "/", false},
+		{"exact path with anchor does not match deeper", "Disallow: /$", "/a", true},
+		{"wildcard in the middle", "Disallow: /search*/results", "/search/q=1/results", false},
+		{"regexp characters are literal", "Disallow: /a.b+c", "/aXb+c", true},
+		{"query string", "Disallow: /*?sort=", "/ranking?sort=new", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseRobots(strings.NewReader("User-agent: *\n"+tt.rules+"\n"), DefaultUserAgent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules.allowed(tt.path); got != tt.want {
+				t.Errorf("allowed(%q) with %q = %v, want %v", tt.path, tt.rules, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitReservesSlotsPerHost(t *testing.T) {
+	s := New(Options{}).(*scraper)
+	const delay = 40 * time.Millisecond
+	const requests = 4
+
+	// 同時に待っても同じホストへの順番は予約した時刻でdelayずつずれる
+	var mu sync.Mutex
+	var times []time.Time
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.wait(context.Background(), "a.example.com", delay); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			times = append(times, time.Now())
+			mu.Unlock()
+		}()
+	}
+	// 別のホストは待たない
+	if err := s.wait(context.Background(), "b.example.com", delay); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > delay/2 {
+		t.Errorf("waiting for another host took %s", elapsed)
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < (requests-1)*delay {
+		t.Errorf("%d requests to one host finished in %s, want at least %s", requests, elapsed, (requests-1)*delay)
+	}
+	sortTimes(times)
+	for i := 1; i < len(times); i++ {
+		// タイマーの誤差を見込んで少し短い間隔まで許す
+		if gap := times[i].Sub(times[i-1]); gap < delay-10*time.Millisecond {
+			t.Errorf("gap between request %d and %d = %s, want about %s", i-1, i, gap, delay)
+		}
+	}
+}
+
+func TestWaitReturnsOnCancel(t *testing.T) {
+	s := New(Options{}).(*scraper)
+	if err := s.wait(context.Background(), "a.example.com", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.wait(ctx, "a.example.com", time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func sortTimes(times []time.Time) {
+	for i := 1; i < len(times); i++ {
+		for j := i; j > 0 && times[j].Before(times[j-1]); j-- {
+			times[j], times[j-1] = times[j-1], times[j]
+		}
+	}
+}
@@ -0,0 +1,172 @@
+//go:build backfill_placeholders
+
+// 取り込み済みの漫画の表紙からBlurHashと代表色を計算して保存する仕組み
+package main
+
+import (
+	"comic-summaries/config"
+	"comic-summaries/entity"
+	"comic-summaries/imagepipeline"
+	"comic-summaries/imagestore"
+	"comic-summaries/repository"
+	"comic-summaries/usecase"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxImageBytes は読み込む表紙画像のサイズの上限です。
+const maxImageBytes = 20 << 20
+
+func main() {
+	ids := flag.String("ids", "", "comma-separated comic IDs to backfill (default: every comic without a placeholder)")
+	force := flag.Bool("force", false, "recompute placeholders that are already set")
+	dryRun := flag.Bool("dry-run", false, "print the computed placeholders without saving them")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout for fetching a single cover")
+	author := flag.String("author", "backfill", "author recorded in the revision history")
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// サーバーと共通の設定 (既定値 < ../.env (GO_ENV=devのみ) < 環境変数 < コマンドライン引数)
+	cfg := loader.MustLoad()
+
+	store, err := imagestore.New(ctx, cfg.ImageStore())
+	if err != nil {
+		log.Fatalf("Error creating image store: %v", err)
+	}
+	client := &http.Client{Timeout: *timeout}
+
+	db := repository.NewDynamoDB(cfg.AWS)
+	if !*dryRun {
+		if err := repository.EnsureRevisionTable(ctx, db); err != nil {
+			log.Fatalf("Error preparing the revision table: %v", err)
+		}
+	}
+	comicUsecase := usecase.NewComicUsecase(repository.NewComicRepository(db), repository.NewRevisionRepository(db))
+	comics, err := comicUsecase.ListEveryComic(ctx)
+	if err != nil {
+		log.Fatalf("Error listing comics: %v", err)
+	}
+
+	wanted := map[int]bool{}
+	for _, s := range strings.Split(*ids, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("Invalid ID %q: %v", s, err)
+		}
+		wanted[id] = true
+	}
+
+	var updated, skipped, failed int
+	for _, comic := range comics {
+		if ctx.Err() != nil {
+			break
+		}
+		if len(wanted) > 0 && !wanted[comic.ID] {
+			continue
+		}
+		if comic.ImagePath == "" || (comic.BlurHash != "" && comic.DominantColor != "" && !*force) {
+			skipped++
+			continue
+		}
+
+		placeholder, err := computePlaceholder(ctx, store, client, *timeout, comic)
+		if err != nil {
+			log.Printf("Error computing the placeholder of %d %s: %v", comic.ID, comic.Title, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\n", comic.ID, comic.Title, placeholder.BlurHash, placeholder.DominantColor)
+		if *dryRun || (placeholder.BlurHash == comic.BlurHash && placeholder.DominantColor == comic.DominantColor) {
+			continue
+		}
+
+		comic.BlurHash = placeholder.BlurHash
+		comic.DominantColor = placeholder.DominantColor
+		// 表紙から計算した値を足すだけのため、要約の来歴 (Source) は変えない
+		comic.UpdatedAt = time.Now().UTC()
+		if err := comicUsecase.SaveComic(ctx, comic, *author, "backfill placeholders"); err != nil {
+			log.Fatalf("Error saving %d %s: %v", comic.ID, comic.Title, err)
+		}
+		updated++
+	}
+
+	fmt.Printf("%d comics updated, %d skipped, %d failed\n", updated, skipped, failed)
+}
+
+// computePlaceholder reads the smallest stored variant of the cover (falling back to the original)
+// and computes its placeholder; the result barely depends on the resolution
+func computePlaceholder(ctx context.Context, store imagestore.ImageStore, client *http.Client, timeout time.Duration, comic *entity.Comic) (imagepipeline.Placeholder, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	imageURL := comic.ImagePath
+	if comic.Images != nil {
+		for _, source := range comic.Images.Sources {
+			if source.Type == "image/jpeg" && len(source.Variants) > 0 {
+				imageURL = source.Variants[0].URL
+			}
+		}
+	}
+
+	data, err := readImage(ctx, store, client, imageURL)
+	if err != nil {
+		return imagepipeline.Placeholder{}, err
+	}
+	img, _, err := imagepipeline.Decode(data)
+	if err != nil {
+		return imagepipeline.Placeholder{}, err
+	}
+	return imagepipeline.ComputePlaceholder(img)
+}
+
+// readImage reads an image from the store when the URL points into it, and over HTTP otherwise
+func readImage(ctx context.Context, store imagestore.ImageStore, client *http.Client, imageURL string) ([]byte, error) {
+	var body io.ReadCloser
+	if key, ok := imagestore.KeyFromURL(store, imageURL); ok {
+		object, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		body = object
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to fetch image: status code %d", resp.StatusCode)
+		}
+		body = resp.Body
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image larger than %d bytes", maxImageBytes)
+	}
+	return data, nil
+}
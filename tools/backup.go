@@ -0,0 +1,47 @@
+//go:build backup
+
+package main
+
+import (
+	"comic-summaries/backup"
+	"comic-summaries/config"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+func main() {
+	tableName := flag.String("table", "ComicSummaries", "table to back up")
+	dir := flag.String("dir", "backups", "directory to write snapshots into")
+	keep := flag.Int("keep", 7, "number of snapshots to keep per table")
+	maxAge := flag.Duration("max-age", 0, "remove snapshots older than this (0 keeps them until -keep is exceeded)")
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	// Load the configuration shared with the server (defaults < ../.env with GO_ENV=dev < environment < flags)
+	cfg := loader.MustLoad()
+
+	// Create DynamoDB client
+	svc, err := cfg.AWS.NewDynamoDBClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config, %v", err)
+	}
+
+	now := time.Now()
+	snapshot, err := backup.Create(context.TODO(), svc, *tableName, *dir, now)
+	if err != nil {
+		log.Fatalf("Failed to create snapshot: %v", err)
+	}
+	fmt.Printf("Snapshot written to %s (%d items, sha256 %s)\n", snapshot.Dir, snapshot.Manifest.ItemCount, snapshot.Manifest.SHA256)
+
+	// Apply the retention policy
+	removed, err := backup.Prune(*dir, *keep, *maxAge, now)
+	if err != nil {
+		log.Fatalf("Failed to prune old snapshots: %v", err)
+	}
+	for _, s := range removed {
+		fmt.Printf("Removed old snapshot %s\n", s.Dir)
+	}
+}
@@ -3,117 +3,140 @@
 package main
 
 import (
+	"comic-summaries/canon"
+	"comic-summaries/comicio"
+	"comic-summaries/config"
+	"comic-summaries/entity"
+	"comic-summaries/repository"
+	"comic-summaries/usecase"
 	"context"
-	"encoding/csv"
+	"flag"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"log"
-	"os"
-	"strconv"
+	"path/filepath"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/joho/godotenv"
 )
 
-type Comic struct {
-	ID         int    `csv:"ID"`
-	Title      string `csv:"Title"`
-	Synopsis   string `csv:"Synopsis"`
-	Attraction string `csv:"Attraction"`
-	Spoilers   string `csv:"Spoilers"`
-	Genre      string `csv:"Genre"`
-	Characters string `csv:"Characters"`
-	ImagePath  string `csv:"ImagePath"`
-}
-
 func main() {
-	// Load environment variables from .env file
-	err := godotenv.Load("../.env")
+	filename := flag.String("file", "data.csv", "input file")
+	formatName := flag.String("format", "", "input format: csv, ndjson or json (default: detected from the file extension)")
+	titleRules := flag.String("title-rules", "", "JSON array of regular expressions used to match titles against existing comics (default: built-in rules)")
+	noHistory := flag.Bool("no-history", false, "batch write every record without recording revisions")
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	format, err := comicio.ResolveFormat(*formatName, *filename)
 	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+		log.Fatalf("Invalid format: %v", err)
 	}
 
-	// Load the AWS configuration
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(os.Getenv("AWS_REGION")), config.WithEndpointResolver(
-		aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-			if service == dynamodb.ServiceID {
-				return aws.Endpoint{
-					URL:           "http://localhost:8000", // DynamoDB Localのエンドポイント
-					SigningRegion: region,
-				}, nil
-			}
-			return aws.Endpoint{}, fmt.Errorf("unknown endpoint requested")
-		}),
-	))
+	// Load the configuration shared with the server (defaults < ../.env with GO_ENV=dev < environment < flags)
+	cfg := loader.MustLoad()
+
+	// Create DynamoDB client
+	svc, err := cfg.AWS.NewDynamoDBClient(context.TODO())
 	if err != nil {
 		log.Fatalf("Unable to load SDK config, %v", err)
 	}
 
-	// Create DynamoDB client
-	svc := dynamodb.NewFromConfig(cfg)
-
-	// Read CSV file
-	records, err := readCSV("data.csv")
+	// Read and validate the input file
+	records, columns, err := comicio.ReadFileColumns(*filename, format)
 	if err != nil {
-		log.Fatalf("Failed to read CSV file: %v", err)
+		log.Fatalf("Failed to read %s: %v", *filename, err)
 	}
+	comicio.StampImported(records, time.Now())
 
-	// Batch write to DynamoDB
-	err = batchWriteToDynamoDB(svc, "ComicSummaries", records)
-	if err != nil {
-		log.Fatalf("Failed to batch write to DynamoDB: %v", err)
+	if *noHistory {
+		// Batch write to DynamoDB
+		err = batchWriteToDynamoDB(svc, "ComicSummaries", records)
+		if err != nil {
+			log.Fatalf("Failed to batch write to DynamoDB: %v", err)
+		}
+	} else {
+		rules := canon.DefaultRules()
+		if *titleRules != "" {
+			rules, err = canon.LoadRules(*titleRules)
+			if err != nil {
+				log.Fatalf("Error loading title rules: %v", err)
+			}
+		}
+		err = importWithHistory(context.Background(), cfg.AWS, records, columns, rules, "import "+filepath.Base(*filename))
+		if err != nil {
+			log.Fatalf("Failed to import: %v", err)
+		}
 	}
 
 	fmt.Println("Data successfully imported to DynamoDB")
 }
 
-// readCSV reads the CSV file and returns a slice of Comic structs
-func readCSV(filename string) ([]Comic, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// importWithHistory saves new and changed records through the usecase so every
+// overwrite leaves a revision behind. Only the columns present in the file are
+// copied onto an existing comic (nil columns means every column), so an older
+// CSV without the image or review columns keeps those values. Unchanged records
+// are skipped, and new records whose title matches an existing comic are
+// flagged with DuplicateOf.
+func importWithHistory(ctx context.Context, awsConfig config.AWS, records []entity.Comic, columns []string, rules *canon.Rules, reason string) error {
+	db := repository.NewDynamoDB(awsConfig)
+	if err := repository.EnsureRevisionTable(ctx, db); err != nil {
+		return err
 	}
-	defer file.Close()
+	comicUsecase := usecase.NewComicUsecase(repository.NewComicRepository(db), repository.NewRevisionRepository(db))
 
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1
-
-	// Read all records
-	records, err := reader.ReadAll()
+	existing, err := comicUsecase.ListEveryComic(ctx)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	byID := make(map[int]*entity.Comic, len(existing))
+	for _, comic := range existing {
+		byID[comic.ID] = comic
 	}
 
-	// Parse CSV records into Comic structs
-	var comics []Comic
-	for i, record := range records {
-		if i == 0 {
-			continue // Skip header
+	index := canon.NewIndex(rules, existing)
+
+	var created, updated, unchanged, flagged int
+	for i := range records {
+		record := records[i]
+		if current, ok := byID[record.ID]; ok {
+			merged, err := comicio.Merge(current, &record, columns)
+			if err != nil {
+				return fmt.Errorf("comic %d: %w", record.ID, err)
+			}
+			record = merged
+			record.CreatedAt = current.CreatedAt
+			// Keep the review state unless the file sets one explicitly
+			if record.Status == "" {
+				record.Status = current.Status
+			}
+			if len(entity.Diff(current, &record)) == 0 {
+				unchanged++
+				continue
+			}
+			record.Touch(entity.SourceImport, time.Now())
+			updated++
+		} else {
+			if match := index.Match(record.Title); match != nil && record.DuplicateOf == 0 {
+				fmt.Printf("possible duplicate: %d %q matches %d %q (%s)\n", record.ID, record.Title, match.Comic.ID, match.Name, match.Kind)
+				record.DuplicateOf = match.Comic.ID
+				flagged++
+			}
+			index.Add(&records[i])
+			created++
 		}
-		id, _ := strconv.Atoi(record[0])
-		comic := Comic{
-			// IDはrecord[0]をintに変換する
-			ID:         id,
-			Title:      record[1],
-			Synopsis:   record[2],
-			Attraction: record[3],
-			Spoilers:   record[4],
-			Genre:      record[5],
-			Characters: record[6],
-			ImagePath:  record[7],
+		if err := comicUsecase.SaveComic(ctx, &record, "import", reason); err != nil {
+			return fmt.Errorf("comic %d: %w", record.ID, err)
 		}
-		comics = append(comics, comic)
 	}
 
-	return comics, nil
+	fmt.Printf("created: %d (%d possible duplicates), updated: %d, unchanged: %d\n", created, flagged, updated, unchanged)
+	return nil
 }
 
 // batchWriteToDynamoDB writes the records to DynamoDB in batches
-func batchWriteToDynamoDB(svc *dynamodb.Client, tableName string, records []Comic) error {
+func batchWriteToDynamoDB(svc *dynamodb.Client, tableName string, records []entity.Comic) error {
 	const batchSize = 25
 	for i := 0; i < len(records); i += batchSize {
 		end := i + batchSize
@@ -0,0 +1,187 @@
+//go:build check_images
+
+// 漫画の画像のURLを画像の保存先と突き合わせ、表示できない表紙を報告・修復する仕組み
+package main
+
+import (
+	"comic-summaries/config"
+	"comic-summaries/entity"
+	"comic-summaries/imagecheck"
+	"comic-summaries/imagestore"
+	"comic-summaries/repository"
+	"comic-summaries/usecase"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	ids := flag.String("ids", "", "comma-separated comic IDs to check (default: every comic)")
+	concurrency := flag.Int("concurrency", imagecheck.DefaultConcurrency, "number of image URLs checked in parallel")
+	timeout := flag.Duration("timeout", imagecheck.DefaultTimeout, "timeout for checking a single image URL")
+	fix := flag.String("fix", "none", "what to do with broken images: none (report only), mark (set ImageBroken) or repair (replace broken URLs, then mark the rest)")
+	httpOnly := flag.Bool("http", false, "check every URL over HTTP, e.g. through CloudFront, instead of asking the image store")
+	reportFile := flag.String("report", "", "write the report as TSV to this file (default: stdout)")
+	author := flag.String("author", "check-images", "author recorded in the revision history")
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	if *fix != "none" && *fix != "mark" && *fix != "repair" {
+		log.Fatalf("Invalid -fix %q (want none, mark or repair)", *fix)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// サーバーと共通の設定 (既定値 < ../.env (GO_ENV=devのみ) < 環境変数 < コマンドライン引数)
+	cfg := loader.MustLoad()
+
+	checker := &imagecheck.Checker{
+		Client:      &http.Client{Timeout: *timeout},
+		Concurrency: *concurrency,
+		Timeout:     *timeout,
+	}
+	if !*httpOnly {
+		store, err := imagestore.New(ctx, cfg.ImageStore())
+		if err != nil {
+			log.Fatalf("Error creating image store: %v", err)
+		}
+		checker.Store = store
+	}
+
+	db := repository.NewDynamoDB(cfg.AWS)
+	if *fix != "none" {
+		if err := repository.EnsureRevisionTable(ctx, db); err != nil {
+			log.Fatalf("Error preparing the revision table: %v", err)
+		}
+	}
+	comicUsecase := usecase.NewComicUsecase(repository.NewComicRepository(db), repository.NewRevisionRepository(db))
+	comics, err := comicUsecase.ListEveryComic(ctx)
+	if err != nil {
+		log.Fatalf("Error listing comics: %v", err)
+	}
+	comics, err = selectComics(comics, *ids)
+	if err != nil {
+		log.Fatalf("Invalid -ids: %v", err)
+	}
+
+	var out io.WriteCloser = os.Stdout
+	if *reportFile != "" {
+		out, err = os.Create(*reportFile)
+		if err != nil {
+			log.Fatalf("Error creating %s: %v", *reportFile, err)
+		}
+	}
+
+	started := time.Now()
+	reports := checker.Check(ctx, comics)
+	if ctx.Err() != nil {
+		log.Fatalf("Interrupted: %v", ctx.Err())
+	}
+
+	fmt.Fprintln(out, "ID\tTitle\tURL\tResult\tAction")
+	var broken, repaired, marked, failed int
+	for i := range reports {
+		report := &reports[i]
+		comic := report.Comic
+		if !report.Broken() && !comic.ImageBroken {
+			continue
+		}
+		before := report.Results
+		wasBroken := report.Broken()
+		if wasBroken {
+			broken++
+		}
+
+		action := ""
+		if *fix != "none" {
+			before = append([]imagecheck.Result(nil), before...)
+			changed := false
+			if *fix == "repair" && wasBroken && checker.Repair(ctx, report) {
+				changed = true
+				if !report.Broken() {
+					action = "repaired"
+					repaired++
+				}
+			}
+			if comic.ImageBroken != report.Broken() {
+				comic.ImageBroken = report.Broken()
+				changed = true
+				if comic.ImageBroken {
+					action = "marked"
+					marked++
+				} else if action == "" {
+					action = "unmarked"
+				}
+			}
+			if changed {
+				// 画像のURLだけを直すため、要約の来歴 (Source) は変えない
+				comic.UpdatedAt = time.Now().UTC()
+				if err := comicUsecase.SaveComic(ctx, comic, *author, "check_images -fix="+*fix); err != nil {
+					log.Printf("Error saving %d %s: %v", comic.ID, comic.Title, err)
+					action = "error: " + err.Error()
+					failed++
+				}
+			}
+		}
+
+		if !wasBroken {
+			// 前回の確認で記録した画像が表示できるようになった
+			fmt.Fprintf(out, "%d\t%s\t%s\tok\t%s\n", comic.ID, comic.Title, comic.ImagePath, action)
+		}
+		for _, result := range before {
+			if !result.OK() {
+				fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\n", comic.ID, comic.Title, result.URL, result, action)
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Checked %d comics in %s: %d with broken images", len(comics), time.Since(started).Round(time.Millisecond), broken)
+	if *fix != "none" {
+		fmt.Fprintf(os.Stderr, ", %d repaired, %d marked, %d failed to save", repaired, marked, failed)
+	}
+	fmt.Fprintln(os.Stderr)
+	if err := out.Close(); err != nil {
+		log.Fatalf("Error writing the report: %v", err)
+	}
+	// レポートだけの場合は、CIなどで検知できるよう壊れた画像があれば失敗にする
+	if *fix == "none" && broken > 0 {
+		os.Exit(1)
+	}
+}
+
+// selectComics returns the comics listed in ids, or every comic when ids is empty
+func selectComics(comics []*entity.Comic, ids string) ([]*entity.Comic, error) {
+	if strings.TrimSpace(ids) == "" {
+		return comics, nil
+	}
+	byID := make(map[int]*entity.Comic, len(comics))
+	for _, comic := range comics {
+		byID[comic.ID] = comic
+	}
+	var selected []*entity.Comic
+	for _, s := range strings.Split(ids, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q: %w", s, err)
+		}
+		comic, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("comic %d not found", id)
+		}
+		selected = append(selected, comic)
+	}
+	return selected, nil
+}
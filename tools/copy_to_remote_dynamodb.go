@@ -1,17 +1,15 @@
 package main
 
 import (
+	"comic-summaries/config"
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/joho/godotenv"
 )
 
 type Comic struct {
@@ -26,40 +24,30 @@ type Comic struct {
 }
 
 func main() {
-	// Load environment variables from .env file
-	err := godotenv.Load("../.env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	// Load the configuration shared with the server (defaults < ../.env with GO_ENV=dev < environment < flags)
+	cfg := loader.MustLoad()
+	if err := cfg.Require("REMOTE_AWS_REGION", "DYNAMODB_ENDPOINT"); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
 
-	// Load the AWS configuration for local DynamoDB
-	localCfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(os.Getenv("AWS_REGION")), config.WithEndpointResolver(
-		aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-			if service == dynamodb.ServiceID {
-				return aws.Endpoint{
-					URL:           "http://localhost:8000", // DynamoDB Localのエンドポイント
-					SigningRegion: region,
-				}, nil
-			}
-			return aws.Endpoint{}, fmt.Errorf("unknown endpoint requested")
-		}),
-	))
+	// Create DynamoDB client for local DynamoDB
+	localSvc, err := cfg.AWS.NewDynamoDBClient(context.TODO())
 	if err != nil {
 		log.Fatalf("Unable to load local SDK config, %v", err)
 	}
 
-	// Create DynamoDB client for local DynamoDB
-	localSvc := dynamodb.NewFromConfig(localCfg)
-
-	// Load the AWS configuration for remote DynamoDB
-	remoteCfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(os.Getenv("REMOTE_AWS_REGION")))
+	// Create DynamoDB client for remote DynamoDB, with the same credentials but no local endpoint
+	remote := cfg.AWS
+	remote.Region = cfg.RemoteAWSRegion
+	remote.DynamoDBEndpoint = ""
+	remoteSvc, err := remote.NewDynamoDBClient(context.TODO())
 	if err != nil {
 		log.Fatalf("Unable to load remote SDK config, %v", err)
 	}
 
-	// Create DynamoDB client for remote DynamoDB
-	remoteSvc := dynamodb.NewFromConfig(remoteCfg)
-
 	// Scan the local DynamoDB table
 	items, err := scanDynamoDBTable(localSvc, "ComicSummaries")
 	if err != nil {
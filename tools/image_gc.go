@@ -0,0 +1,166 @@
+//go:build image_gc
+
+// 画像の保存先と漫画データを突き合わせ、どの漫画からも参照されていない画像と、画像が見つからない漫画を報告する仕組み
+package main
+
+import (
+	"comic-summaries/comicio"
+	"comic-summaries/config"
+	"comic-summaries/entity"
+	"comic-summaries/imagecheck"
+	"comic-summaries/imagestore"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+func main() {
+	deleteOrphans := flag.Bool("delete", false, "delete unreferenced images (default: only report them)")
+	minAge := flag.Duration("min-age", 24*time.Hour, "never delete images newer than this, so images of an ingestion still in progress survive")
+	ignoreRevisions := flag.Bool("ignore-revisions", false, "do not keep images referenced only by the revision history")
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// サーバーと共通の設定 (既定値 < ../.env (GO_ENV=devのみ) < 環境変数 < コマンドライン引数)
+	cfg := loader.MustLoad()
+
+	store, err := imagestore.New(ctx, cfg.ImageStore())
+	if err != nil {
+		log.Fatalf("Error creating image store: %v", err)
+	}
+
+	svc, err := cfg.AWS.NewDynamoDBClient(ctx)
+	if err != nil {
+		log.Fatalf("Unable to load SDK config, %v", err)
+	}
+	comics, err := comicio.ScanComics(ctx, svc, "ComicSummaries")
+	if err != nil {
+		log.Fatalf("Failed to scan comics: %v", err)
+	}
+
+	// 過去のリビジョンに戻したときに画像が消えていないよう、履歴から参照される画像も残す
+	var revisions []entity.Revision
+	if !*ignoreRevisions {
+		revisions, err = scanRevisions(ctx, svc)
+		if err != nil {
+			log.Fatalf("Failed to scan revisions: %v", err)
+		}
+	}
+
+	objects := map[string]imagestore.ObjectInfo{}
+	err = store.List(ctx, func(info imagestore.ObjectInfo) error {
+		objects[info.Key] = info
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to list images: %v", err)
+	}
+
+	referenced := map[string]bool{}
+	external := 0
+	var missing []string
+	for _, comic := range comics {
+		for _, imageURL := range imagecheck.ImageURLs(&comic) {
+			key, ok := imagestore.KeyFromURL(store, imageURL)
+			if !ok {
+				// 保存先の外のURL (他のサイトの画像など) は確認できない
+				external++
+				continue
+			}
+			referenced[key] = true
+			if _, ok := objects[key]; !ok {
+				missing = append(missing, fmt.Sprintf("%d\t%s\t%s", comic.ID, comic.Title, key))
+			}
+		}
+	}
+	for _, revision := range revisions {
+		for _, imageURL := range imagecheck.ImageURLs(&revision.Comic) {
+			if key, ok := imagestore.KeyFromURL(store, imageURL); ok {
+				referenced[key] = true
+			}
+		}
+	}
+
+	var orphans []imagestore.ObjectInfo
+	for key, info := range objects {
+		if !referenced[key] {
+			orphans = append(orphans, info)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Key < orphans[j].Key
+	})
+
+	fmt.Printf("Images in store: %d, referenced: %d, unreferenced: %d\n", len(objects), len(referenced), len(orphans))
+	if external > 0 {
+		fmt.Printf("Skipped %d image URLs outside the store\n", external)
+	}
+
+	fmt.Printf("\nComics with missing images (%d):\n", len(missing))
+	for _, line := range missing {
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\nUnreferenced images (%d):\n", len(orphans))
+	var deleted, kept int
+	var freed int64
+	now := time.Now()
+	for _, info := range orphans {
+		age := now.Sub(info.ModTime)
+		if !*deleteOrphans {
+			fmt.Printf("%s\t%d bytes\t%s old\n", info.Key, info.Size, age.Round(time.Minute))
+			continue
+		}
+		if age < *minAge {
+			fmt.Printf("%s\tkept (%s old)\n", info.Key, age.Round(time.Minute))
+			kept++
+			continue
+		}
+		if err := store.Delete(ctx, info.Key); err != nil {
+			if errors.Is(err, context.Canceled) {
+				log.Fatalf("Interrupted after deleting %d images", deleted)
+			}
+			log.Printf("Failed to delete %s: %v", info.Key, err)
+			continue
+		}
+		fmt.Printf("%s\tdeleted\n", info.Key)
+		deleted++
+		freed += info.Size
+	}
+
+	if *deleteOrphans {
+		fmt.Printf("\nDeleted %d images (%d bytes), kept %d newer than %s\n", deleted, freed, kept, *minAge)
+	} else if len(orphans) > 0 {
+		fmt.Println("\nRun with -delete to remove the unreferenced images")
+	}
+}
+
+// scanRevisions はリビジョンのテーブルを全件読み込みます。テーブルがない場合は空を返します。
+func scanRevisions(ctx context.Context, svc *dynamodb.Client) ([]entity.Revision, error) {
+	items, err := comicio.ScanItems(ctx, svc, "ComicSummaryRevisions")
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var revisions []entity.Revision
+	if err := attributevalue.UnmarshalListOfMaps(items, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
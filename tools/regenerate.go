@@ -0,0 +1,200 @@
+//go:build regenerate
+
+package main
+
+import (
+	"bufio"
+	"comic-summaries/config"
+	"comic-summaries/entity"
+	"comic-summaries/generator"
+	"comic-summaries/prompt"
+	"comic-summaries/repository"
+	"comic-summaries/usecase"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	openai "github.com/sashabaranov/go-openai"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	ids := flag.String("ids", "", "comma-separated comic IDs to regenerate")
+	olderThan := flag.Duration("older-than", 0, "only comics last updated longer ago than this, e.g. 2160h")
+	outdated := flag.Bool("outdated", false, "only comics generated with a prompt other than -prompt-version")
+	genre := flag.String("genre", "", "only comics whose genre contains this text")
+	all := flag.Bool("all", false, "regenerate every comic; required when no other criterion is given")
+	yes := flag.Bool("yes", false, "apply every regenerated summary without asking; published comics keep their summary until the new one is approved in the review queue")
+	author := flag.String("author", os.Getenv("USER"), "author recorded in the revision history")
+	provider := flag.String("provider", "openai", "summary generator: openai, local or fake")
+	model := flag.String("model", "", "model name (default: gpt-4o for openai)")
+	endpoint := flag.String("endpoint", "", "base URL of an OpenAI-compatible endpoint for the local provider")
+	cannedDir := flag.String("canned-dir", "canned", "directory of canned responses for the fake provider")
+	promptVersion := flag.String("prompt-version", prompt.Latest, "version of the embedded prompt template")
+	maxAttempts := flag.Int("max-attempts", 3, "attempts per title, including re-asks after a failed validation")
+	maxRetries := flag.Int("max-retries", 5, "retries per title on rate limit (429) and server (5xx) errors")
+	budget := flag.Float64("budget", 0, "stop before the estimated cost in USD exceeds this amount (0 for no limit; required with -all -yes)")
+	pricesFile := flag.String("prices", "", "JSON price table keyed by model name (default: built-in prices)")
+	promptTokens := flag.Int("prompt-tokens", 2000, "estimated prompt tokens of the first request, used for the budget check (re-asks are reserved too)")
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	// Ctrl-Cで生成中のリクエストを中断する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// サーバーと共通の設定 (既定値 < ../.env (GO_ENV=devのみ) < 環境変数 < コマンドライン引数)
+	cfg := loader.MustLoad()
+	if *provider == "openai" {
+		if err := cfg.Require("OPENAI_API_KEY"); err != nil {
+			log.Fatalf("Invalid configuration:\n%v", err)
+		}
+	}
+
+	criteria := usecase.RegenerateCriteria{
+		All:       *all,
+		OlderThan: *olderThan,
+		Genre:     *genre,
+	}
+	if *outdated {
+		criteria.PromptVersionNot = *promptVersion
+	}
+	for _, s := range strings.Split(*ids, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("Invalid ID %q: %v", s, err)
+		}
+		criteria.IDs = append(criteria.IDs, id)
+	}
+	if criteria.Empty() && !*all {
+		log.Fatal("Specify -ids, -older-than, -outdated or -genre, or pass -all to regenerate every comic")
+	}
+	// 確認なしで全件を再生成する場合は、料金の上限がなければ始めない
+	if *all && *yes && *budget <= 0 {
+		log.Fatal("-all with -yes regenerates every comic without asking; set -budget to cap the cost")
+	}
+
+	tmpl, err := prompt.Load(*promptVersion)
+	if err != nil {
+		log.Fatalf("Error loading prompt: %v", err)
+	}
+	gen, err := generator.New(generator.Config{
+		Provider:    *provider,
+		APIKey:      cfg.OpenAIAPIKey,
+		Model:       *model,
+		Endpoint:    *endpoint,
+		CannedDir:   *cannedDir,
+		Prompt:      tmpl,
+		MaxAttempts: *maxAttempts,
+	})
+	if err != nil {
+		log.Fatalf("Error creating summary generator: %v", err)
+	}
+
+	// トークン使用量と料金を集計し、予算を超える前に止める
+	prices := generator.DefaultPrices
+	if *pricesFile != "" {
+		prices, err = generator.LoadPriceTable(*pricesFile)
+		if err != nil {
+			log.Fatalf("Error loading price table: %v", err)
+		}
+	}
+	reserveModel := *model
+	if reserveModel == "" {
+		reserveModel = openai.GPT4o
+	}
+	accountant, err := generator.NewAccountant(prices, *budget, reserveModel, *promptTokens, *maxAttempts)
+	if err != nil {
+		log.Fatalf("Error setting up the budget (add the model with -prices): %v", err)
+	}
+	defer accountant.Report(os.Stdout)
+	pool := generator.NewPool(gen, generator.PoolOptions{
+		MaxRetries: *maxRetries,
+		Accountant: accountant,
+	})
+
+	db := repository.NewDynamoDB(cfg.AWS)
+	if err := repository.EnsureRevisionTable(ctx, db); err != nil {
+		log.Fatalf("Error preparing the revision table: %v", err)
+	}
+	comicUsecase := usecase.NewComicUsecase(repository.NewComicRepository(db), repository.NewRevisionRepository(db))
+	regenerateUsecase := usecase.NewRegenerateUsecase(comicUsecase, pool)
+
+	comics, err := regenerateUsecase.Select(ctx, criteria)
+	if err != nil {
+		log.Fatalf("Error selecting comics: %v", err)
+	}
+	fmt.Printf("%d comics selected\n", len(comics))
+
+	stdin := bufio.NewReader(os.Stdin)
+	applied := 0
+loop:
+	for _, comic := range comics {
+		if ctx.Err() != nil {
+			break
+		}
+
+		regeneration := regenerateUsecase.Regenerate(ctx, comic)
+		if errors.Is(regeneration.Err, generator.ErrBudgetExceeded) {
+			log.Printf("Budget of $%.2f reached before %d %s; rerun with a larger -budget to continue", *budget, comic.ID, comic.Title)
+			break
+		}
+		if regeneration.Err != nil {
+			log.Printf("Error regenerating %d %s: %v", comic.ID, comic.Title, regeneration.Err)
+			continue
+		}
+		if len(regeneration.Changes) == 0 {
+			fmt.Printf("%d %s: no changes\n", comic.ID, comic.Title)
+			continue
+		}
+
+		printChanges(comic, regeneration.Changes)
+		if !*yes {
+			answer := ask(stdin, "Apply this change? [y/N/a(ll)/q] ")
+			switch answer {
+			case "a", "all":
+				*yes = true
+			case "y", "yes":
+			case "q", "quit":
+				break loop
+			default:
+				continue
+			}
+		}
+
+		if err := regenerateUsecase.Apply(ctx, regeneration, *author); err != nil {
+			log.Fatalf("Error saving %d %s: %v", comic.ID, comic.Title, err)
+		}
+		applied++
+	}
+
+	fmt.Printf("%d of %d comics updated\n", applied, len(comics))
+	if applied > 0 {
+		fmt.Println("Published comics keep their current summary; approve the regenerated ones in the review queue to publish them")
+	}
+}
+
+// printChanges prints a field-level diff between the current record and the regenerated one
+func printChanges(comic *entity.Comic, changes []entity.FieldChange) {
+	fmt.Printf("\n=== %d %s (updated %s, prompt %q) ===\n", comic.ID, comic.Title, comic.UpdatedAt.Format("2006-01-02"), comic.PromptVersion)
+	for _, change := range changes {
+		fmt.Printf("--- %s\n", change.Field)
+		fmt.Printf("- %s\n", change.Old)
+		fmt.Printf("+ %s\n", change.New)
+	}
+}
+
+// ask prints the question and returns the lower-cased answer
+func ask(r *bufio.Reader, question string) string {
+	fmt.Print(question)
+	answer, _ := r.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(answer))
+}
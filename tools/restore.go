@@ -0,0 +1,70 @@
+//go:build restore
+
+package main
+
+import (
+	"bufio"
+	"comic-summaries/backup"
+	"comic-summaries/config"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	snapshotDir := flag.String("snapshot", "", "snapshot directory to restore (default: the latest snapshot of -table in -dir)")
+	dir := flag.String("dir", "backups", "directory containing snapshots")
+	tableName := flag.String("table", "ComicSummaries", "table to restore into; created if it does not exist")
+	otherTable := flag.Bool("allow-other-table", false, "allow restoring a snapshot taken from a different table than -table")
+	yes := flag.Bool("yes", false, "restore into an existing table without asking; comics not in the snapshot are deleted")
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	if *snapshotDir == "" {
+		latest, err := backup.Latest(*dir, *tableName)
+		if err != nil {
+			log.Fatalf("Failed to find a snapshot of %s: %v", *tableName, err)
+		}
+		*snapshotDir = latest.Dir
+	}
+
+	// Load the configuration shared with the server (defaults < ../.env with GO_ENV=dev < environment < flags)
+	cfg := loader.MustLoad()
+
+	// Create DynamoDB client
+	svc, err := cfg.AWS.NewDynamoDBClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config, %v", err)
+	}
+
+	plan, err := backup.PlanRestore(context.TODO(), svc, *snapshotDir, *tableName)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *snapshotDir, err)
+	}
+	if plan.Manifest.Table != *tableName && !*otherTable {
+		log.Fatalf("%s was taken from %s, not %s; pass -allow-other-table to restore it anyway", *snapshotDir, plan.Manifest.Table, *tableName)
+	}
+
+	// 既存のテーブルはスナップショットの時点の内容に置き換わるため、確認してから書き戻す
+	if plan.Existing > 0 && !*yes {
+		fmt.Printf("%s has %d items. Restoring %s (%d items taken from %s at %s) overwrites them and deletes %d items not in the snapshot.\n",
+			*tableName, plan.Existing, *snapshotDir, plan.Manifest.ItemCount, plan.Manifest.Table,
+			plan.Manifest.CreatedAt.Format("2006-01-02 15:04:05"), len(plan.Deleted))
+		fmt.Print("Continue? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer := strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Restore cancelled")
+			return
+		}
+	}
+
+	if err := backup.Restore(context.TODO(), svc, plan); err != nil {
+		log.Fatalf("Failed to restore %s: %v", *snapshotDir, err)
+	}
+
+	fmt.Printf("Restored %d items from %s (taken from %s at %s) into %s, deleted %d items not in the snapshot\n",
+		plan.Manifest.ItemCount, *snapshotDir, plan.Manifest.Table, plan.Manifest.CreatedAt.Format("2006-01-02 15:04:05"), *tableName, len(plan.Deleted))
+}
@@ -7,297 +7,396 @@
 package main
 
 import (
+	"comic-summaries/canon"
+	"comic-summaries/config"
 	"comic-summaries/entity"
+	"comic-summaries/generator"
+	"comic-summaries/imagepipeline"
+	"comic-summaries/imagestore"
+	"comic-summaries/prompt"
+	"comic-summaries/repository"
+	"comic-summaries/scraper"
+	"comic-summaries/usecase"
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"github.com/PuerkitoBio/goquery"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	openai "github.com/sashabaranov/go-openai"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
-const cloudFrontURL = "https://d3pqvcltup9bej.cloudfront.net"
-
 func main() {
-	err := godotenv.Load("../.env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+	provider := flag.String("provider", "openai", "summary generator: openai, local or fake")
+	model := flag.String("model", "", "model name (default: gpt-4o for openai)")
+	endpoint := flag.String("endpoint", "", "base URL of an OpenAI-compatible endpoint for the local provider")
+	cannedDir := flag.String("canned-dir", "canned", "directory of canned responses for the fake provider")
+	promptVersion := flag.String("prompt-version", prompt.Latest, "version of the embedded prompt template")
+	promptFile := flag.String("prompt-file", "", "prompt template file to use instead of an embedded version")
+	language := flag.String("language", "日本語", "language of the generated summaries")
+	minSynopsis := flag.Int("min-synopsis", generator.DefaultLimits.Synopsis, "minimum characters of Synopsis")
+	minAttraction := flag.Int("min-attraction", generator.DefaultLimits.Attraction, "minimum characters of Attraction")
+	minSpoilers := flag.Int("min-spoilers", generator.DefaultLimits.Spoilers, "minimum characters of Spoilers")
+	maxAttempts := flag.Int("max-attempts", 3, "attempts per title, including re-asks after a failed validation")
+	concurrency := flag.Int("concurrency", 4, "number of titles generated in parallel")
+	rpm := flag.Int("rpm", 60, "maximum API requests per minute, counting re-asks (0 for no limit)")
+	tpm := flag.Int("tpm", 0, "maximum tokens per minute, estimated as prompt plus max completion tokens per request (0 for no limit)")
+	maxRetries := flag.Int("max-retries", 5, "retries per title on rate limit (429) and server (5xx) errors")
+	jobDir := flag.String("job", "", "job directory to resume; a new one is created under jobs/ when empty")
+	budget := flag.Float64("budget", 0, "stop before the estimated cost in USD exceeds this amount (0 for no limit)")
+	pricesFile := flag.String("prices", "", "JSON price table keyed by model name (default: built-in prices)")
+	promptTokens := flag.Int("prompt-tokens", 2000, "estimated prompt tokens of the first request, used for the budget check (re-asks are reserved too)")
+	scrapeDelay := flag.Duration("scrape-delay", 2*time.Second, "minimum interval between requests to the ranking site")
+	userAgent := flag.String("user-agent", scraper.DefaultUserAgent, "User-Agent sent to the ranking site and matched against robots.txt")
+	status := flag.String("status", entity.StatusDraft, "review status of the generated summaries: draft, in_review or published")
+	imageWidths := flag.String("image-widths", "160,320,640", "comma-separated widths of the resized cover images")
+	titleRules := flag.String("title-rules", "", "JSON array of regular expressions stripped from scraped titles (default: built-in rules)")
+	loader := config.NewLoader(flag.CommandLine, config.ToolOptions)
+	flag.Parse()
+
+	if !entity.ValidStatus(*status) || *status == entity.StatusRejected {
+		log.Fatalf("Invalid status: %s", *status)
 	}
-
-	prompt, err := os.ReadFile("prompt.txt")
-	if err != nil {
-		log.Fatalf("Error reading prompt file: %v", err)
+	var widths []int
+	for _, w := range strings.Split(*imageWidths, ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(w))
+		if err != nil || width <= 0 {
+			log.Fatalf("Invalid image width: %q", w)
+		}
+		widths = append(widths, width)
 	}
 
-	// forでhttps://comic.k-manga.jp/search/magazine/43?search_option%5Bsort%5D=popular&page=1のpageを1から11まで回す
-	for i := 1; i < 11; i++ {
-		popularTitles, imagePaths := scrapeComicTitlesAndImages("https://comic.k-manga.jp/search/magazine/43?search_option%5Bsort%5D=popular&page="+fmt.Sprintf("%d", i), 50)
-		mangaData := getComicSummaries(popularTitles, imagePaths, string(prompt), os.Getenv("OPENAI_API_KEY"))
-		storeComicData(mangaData)
+	// Ctrl-Cで生成中のリクエストを中断する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// サーバーと共通の設定 (既定値 < ../.env (GO_ENV=devのみ) < 環境変数 < コマンドライン引数)
+	cfg := loader.MustLoad()
+	if *provider == "openai" {
+		if err := cfg.Require("OPENAI_API_KEY"); err != nil {
+			log.Fatalf("Invalid configuration:\n%v", err)
+		}
 	}
-}
 
-func scrapeComicTitlesAndImages(url string, count int) ([]string, []string) {
-	res, err := http.Get(url)
+	var tmpl *prompt.Template
+	var err error
+	if *promptFile != "" {
+		tmpl, err = prompt.LoadFile(*promptFile)
+	} else {
+		tmpl, err = prompt.Load(*promptVersion)
+	}
 	if err != nil {
-		log.Fatalf("Error fetching URL: %v", err)
+		log.Fatalf("Error loading prompt: %v", err)
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != 200 {
-		log.Fatalf("Error: Status code %d", res.StatusCode)
+	gen, err := generator.New(generator.Config{
+		Provider:  *provider,
+		APIKey:    cfg.OpenAIAPIKey,
+		Model:     *model,
+		Endpoint:  *endpoint,
+		CannedDir: *cannedDir,
+		Prompt:    tmpl,
+		Language:  *language,
+		Limits: &generator.Limits{
+			Synopsis:   *minSynopsis,
+			Attraction: *minAttraction,
+			Spoilers:   *minSpoilers,
+		},
+		MaxAttempts: *maxAttempts,
+	})
+	if err != nil {
+		log.Fatalf("Error creating summary generator: %v", err)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	// 生成結果をジョブに記録し、再実行時は生成済みのタイトルを読み飛ばす
+	if *jobDir == "" {
+		*jobDir = filepath.Join("jobs", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	journal, err := generator.OpenJournal(*jobDir, generator.JobManifest{
+		ID:        filepath.Base(*jobDir),
+		CreatedAt: time.Now().UTC(),
+		Provider:  *provider,
+		Model:     *model,
+	})
 	if err != nil {
-		log.Fatalf("Error loading HTTP response body: %v", err)
+		log.Fatalf("Error opening job %s: %v", *jobDir, err)
+	}
+	defer journal.Close()
+	if failed := journal.Failed(); len(failed) > 0 {
+		log.Printf("Resuming job %s: retrying %d failed titles", journal.Dir(), len(failed))
+	} else {
+		log.Printf("Job %s (rerun with -job %s to resume)", journal.Manifest().ID, journal.Dir())
 	}
 
-	var titles []string
-	var imageUrls []string
-	doc.Find(".book-list--item").Each(func(i int, s *goquery.Selection) {
-		title := s.Find(".book-list--title").Text()
-		imageUrl, exists := s.Find(".book-list--img").Attr("src")
-		if exists {
-			titles = append(titles, title)
-			imageUrls = append(imageUrls, imageUrl)
-			fmt.Println(title, imageUrl)
+	// トークン使用量と料金を集計し、予算を超える前に止める
+	prices := generator.DefaultPrices
+	if *pricesFile != "" {
+		prices, err = generator.LoadPriceTable(*pricesFile)
+		if err != nil {
+			log.Fatalf("Error loading price table: %v", err)
 		}
+	}
+	reserveModel := *model
+	if reserveModel == "" {
+		reserveModel = openai.GPT4o
+	}
+	accountant, err := generator.NewAccountant(prices, *budget, reserveModel, *promptTokens, *maxAttempts)
+	if err != nil {
+		log.Fatalf("Error setting up the budget (add the model with -prices): %v", err)
+	}
+	defer accountant.Report(os.Stdout)
+
+	pool := generator.NewPool(gen, generator.PoolOptions{
+		Concurrency:       *concurrency,
+		RequestsPerMinute: *rpm,
+		TokensPerMinute:   *tpm,
+		MaxRetries:        *maxRetries,
+		Journal:           journal,
+		Accountant:        accountant,
 	})
 
-	popularTitles := titles[:count]
-	popularImageUrls := imageUrls[:count]
+	comicScraper := scraper.New(scraper.Options{
+		UserAgent: *userAgent,
+		Delay:     *scrapeDelay,
+	})
 
-	for i, title := range popularTitles {
-		fmt.Printf("%d: %s\n", i+1, title)
-		fmt.Printf("Image URL: %s\n", popularImageUrls[i])
+	// 版・キャンペーン表記を取り除いたタイトルで既存の漫画と照合し、同じ作品は別名として記録する
+	rules := canon.DefaultRules()
+	if *titleRules != "" {
+		rules, err = canon.LoadRules(*titleRules)
+		if err != nil {
+			log.Fatalf("Error loading title rules: %v", err)
+		}
 	}
+	// 追加や別名の更新もリビジョンに記録するため、サーバーと同じユースケースを通して保存する
+	db := repository.NewDynamoDB(cfg.AWS)
+	if err := repository.EnsureRevisionTable(ctx, db); err != nil {
+		log.Fatalf("Error preparing the revision table: %v", err)
+	}
+	comicUsecase := usecase.NewComicUsecase(repository.NewComicRepository(db), repository.NewRevisionRepository(db))
 
-	return popularTitles, popularImageUrls
-}
-
-func uploadToS3(s3Client *s3.Client, bucketName string, filePath string) (string, error) {
-	file, err := os.Open(filePath)
+	// 画像の保存先 (IMAGE_STORE=local|s3)。localの既定値はリポジトリ直下のサーバーと同じ ../images
+	store, err := imagestore.New(ctx, cfg.ImageStore())
 	if err != nil {
-		return "", err
+		log.Fatalf("Error creating image store: %v", err)
 	}
-	defer file.Close()
-
-	// Generate a unique file name for the S3 object
-	key := uuid.New().String() + filepath.Ext(filePath)
-
-	_, err = s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(key),
-		Body:        file,
-		ContentType: aws.String("image/jpeg"), // Adjust the content type as necessary
-	})
+	pipeline := imagepipeline.New(store, widths)
+	existing, err := comicUsecase.ListEveryComic(ctx)
 	if err != nil {
-		return "", err
+		log.Fatalf("Error loading existing comics: %v", err)
+	}
+	index := canon.NewIndex(rules, existing)
+
+	// 新しい漫画には登録済みの最大より大きいIDを割り当てる。同じ実行の中で重複候補として参照できるよう、生成前に割り当てる
+	nextID := 1
+	saved := make(map[int]bool, len(existing))
+	for _, comic := range existing {
+		saved[comic.ID] = true
+		if comic.ID >= nextID {
+			nextID = comic.ID + 1
+		}
 	}
 
-	return fmt.Sprintf("%s/%s", cloudFrontURL, key), nil
-}
+	// forでhttps://comic.k-manga.jp/search/magazine/43?search_option%5Bsort%5D=popular&page=1のpageを1から11まで回す
+	for i := 1; i < 11; i++ {
+		pageURL := "https://comic.k-manga.jp/search/magazine/43?search_option%5Bsort%5D=popular&page=" + fmt.Sprintf("%d", i)
+		items, err := comicScraper.Scrape(ctx, pageURL, 50)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Printf("Interrupted while scraping page %d; rerun with -job %s to resume", i, journal.Dir())
+				return
+			}
+			log.Printf("Skipping page %d: %v", i, err)
+			continue
+		}
+		var pending []*entity.Comic
+		var imagePaths []string
+		for j, item := range items {
+			fmt.Printf("%d: %s\n", j+1, item.Title)
+			fmt.Printf("Image URL: %s\n", item.ImageURL)
+
+			title := rules.Canonical(item.Title)
+			match := index.Match(title)
+			if match != nil && match.Kind == canon.MatchExact {
+				// 登録済みの作品は生成せず、新しい表記を別名に加える。同じページで生成中の漫画は一緒に保存する
+				if match.Comic.AddAlias(item.Title) && saved[match.Comic.ID] {
+					if err := storeAlias(ctx, comicUsecase, match.Comic.ID, item.Title); err != nil {
+						log.Fatalf("Error storing aliases of %s: %v", match.Comic.Title, err)
+					}
+				}
+				log.Printf("Skipping %s: same comic as %s", item.Title, match.Comic.Title)
+				continue
+			}
 
-func downloadImageAndUploadToS3(url string, s3Client *s3.Client, bucketName string) (string, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+			comic := &entity.Comic{ID: nextID, Title: title, Status: *status}
+			nextID++
+			comic.AddAlias(item.Title)
+			if match != nil {
+				// 続編や番外編の可能性もあるため、生成はするがレビューで判断できるよう下書きにして重複候補を記録する
+				log.Printf("Possible duplicate: %s looks like %s", item.Title, match.Name)
+				comic.DuplicateOf = match.Comic.ID
+				comic.Status = entity.StatusDraft
+			}
+			index.Add(comic)
+			pending = append(pending, comic)
+			imagePaths = append(imagePaths, item.ImageURL)
+		}
+		mangaData := getComicSummaries(ctx, pool, journal, pipeline, pending, imagePaths, pageURL)
+		for _, comic := range mangaData {
+			saved[comic.ID] = true
+		}
+		for j := range mangaData {
+			// 生成に失敗した漫画のIDは保存されず、次の実行で別の漫画に使われるため参照しない
+			if dup := mangaData[j].DuplicateOf; dup != 0 && !saved[dup] {
+				log.Printf("Clearing DuplicateOf of %s: comic %d was not stored", mangaData[j].Title, dup)
+				mangaData[j].DuplicateOf = 0
+			}
+		}
+		if err := storeComicData(ctx, comicUsecase, mangaData); err != nil {
+			log.Fatalf("Error storing comics: %v", err)
+		}
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to fetch image: status code %d", resp.StatusCode)
+		if accountant.Exceeded() {
+			log.Printf("Budget of $%.2f reached after page %d; rerun with -job %s and a larger -budget to continue", *budget, i, journal.Dir())
+			return
+		}
+		if ctx.Err() != nil {
+			log.Printf("Interrupted: stored %d comics generated before page %d was cancelled; rerun with -job %s to resume", len(mangaData), i, journal.Dir())
+			return
+		}
 	}
+}
 
-	// Save the image temporarily to upload to S3
-	ext := filepath.Ext(url)
-	fileName := uuid.New().String() + ext
-	filePath := filepath.Join("temp", fileName)
+// maxImageBytes は取得する表紙画像のサイズの上限です。
+const maxImageBytes = 20 << 20
 
-	// Create temp directory if not exists
-	err = os.MkdirAll("temp", os.ModePerm)
+// downloadImage はランキングページの画像を取得し、元画像とサイズ・形式違いの画像を保存します。
+// 戻り値は元画像のURLと、書き出した画像の一覧とプレースホルダーです。
+func downloadImage(ctx context.Context, pipeline *imagepipeline.Pipeline, imageURL string) (*imagepipeline.Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-
-	out, err := os.Create(filePath)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer out.Close()
+	defer resp.Body.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return "", err
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to fetch image: status code %d", resp.StatusCode)
 	}
 
-	// Upload to S3
-	s3Url, err := uploadToS3(s3Client, bucketName, filePath)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-
-	// Clean up temp file
-	err = os.Remove(filePath)
-	if err != nil {
-		log.Printf("Error removing temp file: %v", err)
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image larger than %d bytes", maxImageBytes)
 	}
 
-	return s3Url, nil
+	// 内容のハッシュをキーにするため、同じ表紙を取り込み直しても保存し直さない。形式は内容から判定するため、キーには拡張子を付けない
+	return pipeline.Process(ctx, imagepipeline.ContentKey(data), data)
 }
 
-func getComicSummaries(titles []string, imageUrls []string, prompt string, apiKey string) []entity.Comic {
-	client := openai.NewClient(apiKey)
-
-	// S3クライアントの設定
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(os.Getenv("AWS_REGION")))
-	if err != nil {
-		log.Fatalf("Error loading AWS config: %v", err)
+// getComicSummaries は取り込む漫画の要約を生成し、pendingの各漫画に書き込みます。生成や表紙の取得に失敗した漫画は結果に含めません。
+func getComicSummaries(ctx context.Context, pool *generator.Pool, journal *generator.Journal, pipeline *imagepipeline.Pipeline, pending []*entity.Comic, imageUrls []string, sourceURL string) []entity.Comic {
+	titles := make([]string, len(pending))
+	for i, comic := range pending {
+		titles[i] = comic.Title
 	}
-	s3Client := s3.NewFromConfig(cfg)
-	bucketName := "comic-summaries"
 
 	var mangaData []entity.Comic
-	// TODO:getComicSummariesを複数実行するとIDが被ってしまう
-	for i, title := range titles {
-		req := openai.ChatCompletionRequest{
-			Model: openai.GPT4o,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    "system",
-					Content: prompt,
-				},
-				{
-					Role:    "user",
-					Content: title,
-				},
-			},
-			MaxTokens: 4000,
-			// ResponseFormatにJSONを指定する
-			ResponseFormat: &openai.ChatCompletionResponseFormat{
-				Type: openai.ChatCompletionResponseFormatTypeJSONObject, // JSON形式でレスポンスを指定
-			},
-		}
-
-		resp, err := client.CreateChatCompletion(context.Background(), req)
-		if err != nil {
-			log.Printf("Error calling OpenAI API: %v", err)
+	for _, result := range pool.Run(ctx, titles) {
+		i, title, summary := result.Index, result.Title, result.Summary
+		if result.Err != nil {
+			log.Printf("Error generating summary for %s: %v", title, result.Err)
 			continue
 		}
-
-		jsonData := resp.Choices[0].Message.Content
-		// jsonDataの先頭に```のような不要な文字が含まれていることがあるため不要な文字を全て削除
-		jsonData = strings.ReplaceAll(jsonData, "`", "")
-		log.Printf("output: %s\n%s", title, jsonData)
-
-		var tempData map[string]interface{}
-		if err := json.Unmarshal([]byte(jsonData), &tempData); err != nil {
-			log.Printf("Error unmarshalling JSON data: %v", err)
-			continue
+		if result.Resumed {
+			log.Printf("Reusing summary of %s from the job journal", title)
 		}
 
-		imagePath, err := downloadImageAndUploadToS3(imageUrls[i], s3Client, bucketName)
+		// 表紙を取得できない漫画は失敗として記録し、残りの漫画の取り込みを続ける。要約は記録に残るため、再実行では生成し直さない
+		image, err := downloadImage(ctx, pipeline, imageUrls[i])
 		if err != nil {
-			log.Fatalf("Error uploading image to S3: %v", err)
+			log.Printf("Error downloading image of %s from %s: %v", title, imageUrls[i], err)
+			if err := journal.Fail(title, fmt.Errorf("download image: %w", err)); err != nil {
+				log.Fatalf("Error recording %s in the job journal: %v", title, err)
+			}
+			continue
 		}
-
-		comic := entity.Comic{
-			ID:         i + 1,
-			Title:      title,
-			Synopsis:   getString(tempData, "Synopsis"),
-			Attraction: getString(tempData, "Attraction"),
-			Spoilers:   getString(tempData, "Spoilers"),
-			Genre:      getString(tempData, "Genre"),
-			Characters: getString(tempData, "Characters"),
-			ImagePath:  imagePath,
+		if result.Resumed {
+			// 前回は画像の取得に失敗していた場合に、完了として記録し直す
+			if err := journal.Record(generator.Result{Title: title, Summary: summary, Usage: summary.Usage}); err != nil {
+				log.Fatalf("Error recording %s in the job journal: %v", title, err)
+			}
 		}
 
-		mangaData = append(mangaData, comic)
-
-		fmt.Println(title + "" + jsonData)
+		comic := pending[i]
+		comic.Synopsis = summary.Synopsis
+		comic.Attraction = summary.Attraction
+		comic.Spoilers = summary.Spoilers
+		comic.Genre = summary.Genre
+		comic.Characters = summary.Characters
+		comic.ImagePath = image.URL
+		comic.Images = image.Images
+		comic.BlurHash = image.Placeholder.BlurHash
+		comic.DominantColor = image.Placeholder.DominantColor
+		// どのモデル・プロンプトで生成したかを記録し、古いプロンプトの要約を再生成できるようにする
+		comic.Model = summary.Usage.Model
+		comic.PromptVersion = summary.PromptVersion
+		comic.SourceURL = sourceURL
+		comic.Touch(entity.SourceLLM, time.Now())
+
+		mangaData = append(mangaData, *comic)
+
+		fmt.Printf("%s: %+v\n", title, *summary)
 	}
 
 	return mangaData
 }
 
-func getString(data map[string]interface{}, key string) string {
-	if val, ok := data[key]; ok {
-		return val.(string)
+// storeComicData は生成した漫画を新しい漫画として保存し、最初のリビジョンを記録します。
+// IDが使用済みの場合は別の漫画を上書きしないよう、保存せずにエラーを返します。
+func storeComicData(ctx context.Context, comicUsecase usecase.IComicUsecase, mangaData []entity.Comic) error {
+	for i := range mangaData {
+		manga := &mangaData[i]
+		current, err := comicUsecase.GetComicByID(ctx, strconv.Itoa(manga.ID))
+		if err != nil {
+			return err
+		}
+		if current != nil {
+			return fmt.Errorf("ID %d for %s is already used by %s; another import may be running", manga.ID, manga.Title, current.Title)
+		}
+		// 同時に同じIDで保存された場合は、リビジョンの番号が重複するためErrConcurrentEditになり上書きしない
+		if err := comicUsecase.SaveComic(ctx, manga, "summary_adder", "generated from "+manga.SourceURL); err != nil {
+			return fmt.Errorf("save %s: %w", manga.Title, err)
+		}
 	}
-	return ""
+
+	fmt.Println("データの追加が完了しました。")
+	return nil
 }
 
-func storeComicData(mangaData []entity.Comic) {
-	awsRegion := os.Getenv("AWS_REGION")
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(awsRegion),
-		config.WithEndpointResolver(aws.EndpointResolverFunc(
-			func(service, region string) (aws.Endpoint, error) {
-				if service == dynamodb.ServiceID && region == awsRegion {
-					return aws.Endpoint{
-						PartitionID:   "aws",
-						URL:           "http://localhost:8000",
-						SigningRegion: awsRegion,
-					}, nil
-				}
-				return aws.Endpoint{}, fmt.Errorf("unknown endpoint requested")
-			}),
-		),
-	)
+// storeAlias は登録済みの漫画を読み直して別名を加え、リビジョンを記録して保存します。
+func storeAlias(ctx context.Context, comicUsecase usecase.IComicUsecase, id int, alias string) error {
+	comic, err := comicUsecase.GetComicByID(ctx, strconv.Itoa(id))
 	if err != nil {
-		log.Fatalf("Error loading AWS config: %v", err)
+		return err
 	}
-
-	svc := dynamodb.NewFromConfig(cfg)
-
-	for _, manga := range mangaData {
-		item, err := attributevalue.MarshalMap(manga)
-		if err != nil {
-			log.Fatalf("Error marshalling item: %v", err)
-		}
-
-		_, err = svc.PutItem(context.TODO(), &dynamodb.PutItemInput{
-			TableName:           aws.String("ComicSummaries"),
-			Item:                item,
-			ConditionExpression: aws.String("attribute_not_exists(ID)"),
-		})
-		if err != nil {
-			// If item exists, update it instead of adding a new item
-			_, err = svc.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
-				TableName: aws.String("ComicSummaries"),
-				Key: map[string]types.AttributeValue{
-					"ID":    &types.AttributeValueMemberN{Value: string(manga.ID)},
-					"Title": &types.AttributeValueMemberS{Value: manga.Title},
-				},
-				UpdateExpression: aws.String("set Synopsis = :s, Attraction = :a, Spoilers = :sp, Genre = :g, Characters = :c, ImagePath = :ip"),
-				ExpressionAttributeValues: map[string]types.AttributeValue{
-					":s":  &types.AttributeValueMemberS{Value: manga.Synopsis},
-					":a":  &types.AttributeValueMemberS{Value: manga.Attraction},
-					":sp": &types.AttributeValueMemberS{Value: manga.Spoilers},
-					":g":  &types.AttributeValueMemberS{Value: manga.Genre},
-					":c":  &types.AttributeValueMemberS{Value: manga.Characters},
-					":ip": &types.AttributeValueMemberS{Value: manga.ImagePath},
-				},
-			})
-			if err != nil {
-				log.Fatalf("Error updating item in DynamoDB: %v", err)
-			}
-		}
+	if comic == nil {
+		return fmt.Errorf("comic %d not found", id)
 	}
-
-	fmt.Println("データの追加および更新が完了しました。")
+	if !comic.AddAlias(alias) {
+		return nil
+	}
+	return comicUsecase.SaveComic(ctx, comic, "summary_adder", "add alias "+alias)
 }
@@ -6,7 +6,11 @@ import (
 	"comic-summaries/entity"
 	"comic-summaries/repository"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"strconv"
+	"time"
 )
 
 type IComicUsecase interface {
@@ -14,16 +18,42 @@ type IComicUsecase interface {
 	GetAllComics(ctx context.Context, page int) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error)
 	SearchComicsByTitle(ctx context.Context, title string) ([]*entity.Comic, error)
 	GetTotalCount(ctx context.Context) (int, error)
+	GetPublishedComicByID(ctx context.Context, id string) (*entity.Comic, error)
+	GetPublishedComics(ctx context.Context, page int) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error)
+	SearchPublishedComicsByTitle(ctx context.Context, title string) ([]*entity.Comic, error)
+	GetPublishedCount(ctx context.Context) (int, error)
+	ListEveryComic(ctx context.Context) ([]*entity.Comic, error)
+	SaveComic(ctx context.Context, comic *entity.Comic, author string, reason string) error
+	EditComic(ctx context.Context, id int, edit ComicEdit, author string, reason string) (*entity.Comic, error)
+}
+
+// ErrComicNotFound は指定したIDの漫画がないことを表します。
+var ErrComicNotFound = errors.New("comic not found")
+
+// ErrConcurrentEdit は読み込んでから保存するまでの間に、同じ漫画への別の編集が保存されたことを表します。
+var ErrConcurrentEdit = errors.New("comic was changed by another edit; reload and try again")
+
+// ComicEdit は管理画面から編集するフィールドです。nilのフィールドは変更しません。
+type ComicEdit struct {
+	Title      *string `json:"title"`
+	Synopsis   *string `json:"synopsis"`
+	Attraction *string `json:"attraction"`
+	Spoilers   *string `json:"spoilers"`
+	Genre      *string `json:"genre"`
+	Characters *string `json:"characters"`
+	ImagePath  *string `json:"image_path"`
 }
 
 type comicUsecase struct {
-	comicRepo repository.IComicRepository
+	comicRepo    repository.IComicRepository
+	revisionRepo repository.IRevisionRepository
 }
 
 // NewComicUsecase は新しいcomicUsecaseインスタンスを生成します。
-func NewComicUsecase(repo repository.IComicRepository) IComicUsecase {
+func NewComicUsecase(repo repository.IComicRepository, revisionRepo repository.IRevisionRepository) IComicUsecase {
 	return &comicUsecase{
-		comicRepo: repo,
+		comicRepo:    repo,
+		revisionRepo: revisionRepo,
 	}
 }
 
@@ -32,20 +62,25 @@ func (u *comicUsecase) GetComicByID(ctx context.Context, id string) (*entity.Com
 }
 
 func (u *comicUsecase) GetAllComics(ctx context.Context, page int) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
+	return paginate(ctx, page, u.comicRepo.FindAll)
+}
+
+// paginate はfindで1ページ目から順に読み進め、pageページ目を返します。
+func paginate(ctx context.Context, page int, find func(ctx context.Context, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error)) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
 	limit := 10
 	var lastEvaluatedKey map[string]*dynamodb.AttributeValue = nil
 	if page > 1 {
 		// 前のページの最後のキーを計算して設定する
 		for i := 1; i < page; i++ {
 			var err error
-			_, lastEvaluatedKey, err = u.comicRepo.FindAll(ctx, limit, lastEvaluatedKey)
+			_, lastEvaluatedKey, err = find(ctx, limit, lastEvaluatedKey)
 			if err != nil {
 				return nil, nil, err
 			}
 		}
 	}
 
-	return u.comicRepo.FindAll(ctx, limit, lastEvaluatedKey)
+	return find(ctx, limit, lastEvaluatedKey)
 }
 
 func (u *comicUsecase) SearchComicsByTitle(ctx context.Context, title string) ([]*entity.Comic, error) {
@@ -55,3 +90,150 @@ func (u *comicUsecase) SearchComicsByTitle(ctx context.Context, title string) ([
 func (u *comicUsecase) GetTotalCount(ctx context.Context) (int, error) {
 	return u.comicRepo.GetTotalCount(ctx)
 }
+
+// GetPublishedComicByID は公開中の漫画を返します。公開前の漫画はnilを返します。
+// 公開中の漫画を返すメソッドは、レビュー前の再生成した要約を含めません。
+func (u *comicUsecase) GetPublishedComicByID(ctx context.Context, id string) (*entity.Comic, error) {
+	comic, err := u.comicRepo.FindByID(ctx, id)
+	if err != nil || comic == nil || !comic.Published() {
+		return nil, err
+	}
+	comic.Pending = nil
+	return comic, nil
+}
+
+func (u *comicUsecase) GetPublishedComics(ctx context.Context, page int) ([]*entity.Comic, map[string]*dynamodb.AttributeValue, error) {
+	comics, lastEvaluatedKey, err := paginate(ctx, page, u.comicRepo.FindAllPublished)
+	return withoutPending(comics), lastEvaluatedKey, err
+}
+
+func (u *comicUsecase) SearchPublishedComicsByTitle(ctx context.Context, title string) ([]*entity.Comic, error) {
+	comics, err := u.comicRepo.FindPublishedByTitle(ctx, title)
+	return withoutPending(comics), err
+}
+
+// withoutPending はレビュー前の要約を公開しないよう、漫画からレビュー待ちの要約を取り除きます。
+func withoutPending(comics []*entity.Comic) []*entity.Comic {
+	for _, comic := range comics {
+		comic.Pending = nil
+	}
+	return comics
+}
+
+func (u *comicUsecase) GetPublishedCount(ctx context.Context) (int, error) {
+	return u.comicRepo.GetPublishedCount(ctx)
+}
+
+// ListEveryComic は全ての漫画をページングしながら読み込みます。
+func (u *comicUsecase) ListEveryComic(ctx context.Context) ([]*entity.Comic, error) {
+	const limit = 100
+	var comics []*entity.Comic
+	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
+	for {
+		page, key, err := u.comicRepo.FindAll(ctx, limit, lastEvaluatedKey)
+		if err != nil {
+			return nil, err
+		}
+		comics = append(comics, page...)
+		if len(key) == 0 {
+			return comics, nil
+		}
+		lastEvaluatedKey = key
+	}
+}
+
+// SaveComic は漫画を保存し、変更後の内容をリビジョンとして記録します。
+// 履歴のない漫画を初めて書き換える場合は、上書きされる内容も最初のリビジョンとして残します。
+// リビジョンを番号の重複を許さずに先に保存し、その後で漫画を保存します。同時に別の編集が保存された場合は
+// 漫画を書き換えずにErrConcurrentEditを返します。漫画の保存に失敗した場合は、反映されなかった内容のリビジョンが残ります。
+func (u *comicUsecase) SaveComic(ctx context.Context, comic *entity.Comic, author string, reason string) error {
+	current, err := u.comicRepo.FindByID(ctx, strconv.Itoa(comic.ID))
+	if err != nil {
+		return err
+	}
+	revisions, err := u.revisionRepo.FindByComicID(ctx, comic.ID)
+	if err != nil {
+		return err
+	}
+
+	next := 1
+	if len(revisions) > 0 {
+		next = revisions[len(revisions)-1].Revision + 1
+	} else if current != nil {
+		baseline := &entity.Revision{
+			ComicID:   current.ID,
+			Revision:  next,
+			Comic:     *current,
+			Author:    "system",
+			Reason:    "baseline before the first tracked change",
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := u.createRevision(ctx, baseline); err != nil {
+			return err
+		}
+		next++
+	}
+
+	err = u.createRevision(ctx, &entity.Revision{
+		ComicID:   comic.ID,
+		Revision:  next,
+		Comic:     *comic,
+		Author:    author,
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return u.comicRepo.Save(ctx, comic)
+}
+
+// createRevision はリビジョンを保存します。同じ番号のリビジョンが保存済みの場合は、読み込んだ後に
+// 別の編集が保存されたためErrConcurrentEditを返します。
+func (u *comicUsecase) createRevision(ctx context.Context, revision *entity.Revision) error {
+	err := u.revisionRepo.Create(ctx, revision)
+	if errors.Is(err, repository.ErrRevisionExists) {
+		return fmt.Errorf("%w (revision %d of comic %d)", ErrConcurrentEdit, revision.Revision, revision.ComicID)
+	}
+	return err
+}
+
+// EditComic は管理画面からの編集を反映して保存します。変更前の内容はリビジョンとして残ります。
+func (u *comicUsecase) EditComic(ctx context.Context, id int, edit ComicEdit, author string, reason string) (*entity.Comic, error) {
+	comic, err := u.comicRepo.FindByID(ctx, strconv.Itoa(id))
+	if err != nil {
+		return nil, err
+	}
+	if comic == nil {
+		return nil, ErrComicNotFound
+	}
+
+	fields := []struct {
+		value *string
+		field *string
+	}{
+		{edit.Title, &comic.Title},
+		{edit.Synopsis, &comic.Synopsis},
+		{edit.Attraction, &comic.Attraction},
+		{edit.Spoilers, &comic.Spoilers},
+		{edit.Genre, &comic.Genre},
+		{edit.Characters, &comic.Characters},
+		{edit.ImagePath, &comic.ImagePath},
+	}
+	// 表紙を差し替えた場合、古い表紙のプレースホルダーは使えないためbackfill_placeholdersで計算し直す
+	if edit.ImagePath != nil && *edit.ImagePath != comic.ImagePath {
+		comic.BlurHash = ""
+		comic.DominantColor = ""
+	}
+	for _, f := range fields {
+		if f.value != nil {
+			*f.field = *f.value
+		}
+	}
+	comic.Touch(entity.SourceAdmin, time.Now())
+
+	if err := u.SaveComic(ctx, comic, author, reason); err != nil {
+		return nil, err
+	}
+	return comic, nil
+}
@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/repository"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// racingRevisionRepository は最初のCreateの直前に、別の編集が同じ番号のリビジョンを保存したように振る舞います。
+type racingRevisionRepository struct {
+	repository.IRevisionRepository
+	raced bool
+}
+
+func (r *racingRevisionRepository) Create(ctx context.Context, revision *entity.Revision) error {
+	if !r.raced {
+		r.raced = true
+		other := *revision
+		other.Author = "other editor"
+		if err := r.IRevisionRepository.Create(ctx, &other); err != nil {
+			return err
+		}
+	}
+	return r.IRevisionRepository.Create(ctx, revision)
+}
+
+func TestSaveComicRecordsBaselineAndRevision(t *testing.T) {
+	ctx := context.Background()
+	comicRepo := repository.NewMemoryComicRepository([]entity.Comic{{ID: 1, Title: "漫画", Synopsis: "元のあらすじ"}})
+	revisionRepo := repository.NewMemoryRevisionRepository()
+	u := NewComicUsecase(comicRepo, revisionRepo)
+
+	if err := u.SaveComic(ctx, &entity.Comic{ID: 1, Title: "漫画", Synopsis: "新しいあらすじ"}, "editor", "fix"); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := revisionRepo.FindByComicID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Comic.Synopsis != "元のあらすじ" || revisions[1].Comic.Synopsis != "新しいあらすじ" {
+		t.Fatalf("revisions = %+v, want the baseline and the change", revisions)
+	}
+	comic, err := comicRepo.FindByID(ctx, "1")
+	if err != nil || comic.Synopsis != "新しいあらすじ" {
+		t.Errorf("saved comic = %+v, %v", comic, err)
+	}
+}
+
+func TestSaveComicConcurrentEditLeavesComicUnchanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		history bool // 競合する前にリビジョンがあるか
+	}{
+		{"baseline", false},
+		{"next revision", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			comicRepo := repository.NewMemoryComicRepository([]entity.Comic{{ID: 1, Title: "漫画", Synopsis: "元のあらすじ"}})
+			revisionRepo := repository.NewMemoryRevisionRepository()
+			if tt.history {
+				if err := revisionRepo.Create(ctx, &entity.Revision{ComicID: 1, Revision: 1, Comic: entity.Comic{ID: 1, Title: "漫画", Synopsis: "元のあらすじ"}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			u := NewComicUsecase(comicRepo, &racingRevisionRepository{IRevisionRepository: revisionRepo})
+
+			err := u.SaveComic(ctx, &entity.Comic{ID: 1, Title: "漫画", Synopsis: "負けた編集"}, "editor", "fix")
+			if !errors.Is(err, ErrConcurrentEdit) {
+				t.Fatalf("err = %v, want ErrConcurrentEdit", err)
+			}
+			comic, err := comicRepo.FindByID(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if comic.Synopsis != "元のあらすじ" {
+				t.Errorf("comic was overwritten by the losing edit: %+v", comic)
+			}
+		})
+	}
+}
+
+// unpublishedComics は公開中の漫画の間に公開前の漫画を挟んだデータです。公開中はID 3, 6, 9, ...の漫画と状態のないID 1です。
+func unpublishedComics() []entity.Comic {
+	comics := []entity.Comic{{ID: 1, Title: "漫画1"}}
+	statuses := []string{entity.StatusDraft, entity.StatusPublished, entity.StatusInReview, entity.StatusRejected}
+	for id := 2; id <= 30; id++ {
+		status := statuses[id%len(statuses)]
+		if id%3 == 0 {
+			status = entity.StatusPublished
+		} else if status == entity.StatusPublished {
+			status = entity.StatusDraft
+		}
+		comics = append(comics, entity.Comic{ID: id, Title: "漫画" + strconv.Itoa(id), Status: status})
+	}
+	return comics
+}
+
+func TestPublishedComicsHideUnpublished(t *testing.T) {
+	ctx := context.Background()
+	u := NewComicUsecase(repository.NewMemoryComicRepository(unpublishedComics()), repository.NewMemoryRevisionRepository())
+
+	var published []int
+	for page := 1; ; page++ {
+		comics, key, err := u.GetPublishedComics(ctx, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		published = append(published, comicIDs(comics)...)
+		if key == nil {
+			break
+		}
+	}
+	want := []int{1, 3, 6, 9, 12, 15, 18, 21, 24, 27, 30}
+	if !equalInts(published, want) {
+		t.Errorf("published pages = %v, want %v", published, want)
+	}
+	if count, err := u.GetPublishedCount(ctx); err != nil || count != len(want) {
+		t.Errorf("GetPublishedCount = %d, %v, want %d", count, err, len(want))
+	}
+
+	// 管理者向けの一覧は全ての漫画を返す
+	if comics, _, err := u.GetAllComics(ctx, 1); err != nil || len(comics) != 10 || comics[1].ID != 2 {
+		t.Errorf("GetAllComics = %v, %v", comicIDs(comics), err)
+	}
+}
+
+func TestPublishedLookupAndSearchHideUnpublished(t *testing.T) {
+	ctx := context.Background()
+	u := NewComicUsecase(repository.NewMemoryComicRepository(unpublishedComics()), repository.NewMemoryRevisionRepository())
+
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"1", true},
+		{"2", false}, // in_review
+		{"3", true},
+		{"4", false},  // draft
+		{"7", false},  // rejected
+		{"99", false}, // 存在しない
+	}
+	for _, tt := range tests {
+		comic, err := u.GetPublishedComicByID(ctx, tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (comic != nil) != tt.want {
+			t.Errorf("GetPublishedComicByID(%s) = %+v, want found %v", tt.id, comic, tt.want)
+		}
+	}
+
+	// "漫画2" は漫画2, 漫画20〜29に一致するが、公開中は漫画21, 24, 27だけ
+	comics, err := u.SearchPublishedComicsByTitle(ctx, "漫画2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := comicIDs(comics); !equalInts(got, []int{21, 24, 27}) {
+		t.Errorf("SearchPublishedComicsByTitle = %v, want [21 24 27]", got)
+	}
+	all, err := u.SearchComicsByTitle(ctx, "漫画2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 11 {
+		t.Errorf("SearchComicsByTitle = %v, want every match", comicIDs(all))
+	}
+}
@@ -0,0 +1,111 @@
+// usecase/health_usecase.go
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HealthCheck は準備完了の確認に使う依存先の1つです。
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Timeout はこの確認だけの時間制限です。0の場合はNewHealthUsecaseに渡した既定値を使います。
+	Timeout time.Duration
+}
+
+// CheckResult は1つの確認の結果です。
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Readiness は全ての確認の結果です。1つでも失敗した場合はStatusが "unavailable" になります。
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Ready はリクエストを受け付けられる状態であるかを返します。
+func (r *Readiness) Ready() bool {
+	return r.Status == "ready"
+}
+
+type IHealthUsecase interface {
+	Readiness(ctx context.Context) *Readiness
+}
+
+type healthUsecase struct {
+	timeout time.Duration
+	checks  []HealthCheck
+}
+
+// NewHealthUsecase はchecksで準備完了を確認するIHealthUsecaseを生成します。timeoutは各確認の既定の時間制限です。
+func NewHealthUsecase(timeout time.Duration, checks ...HealthCheck) IHealthUsecase {
+	return &healthUsecase{
+		timeout: timeout,
+		checks:  checks,
+	}
+}
+
+// Readiness は全ての確認を並行して実行します。時間制限を過ぎた確認は待たずに失敗とします。
+func (u *healthUsecase) Readiness(ctx context.Context) *Readiness {
+	readiness := &Readiness{
+		Status: "ready",
+		Checks: make(map[string]CheckResult, len(u.checks)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range u.checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+			result := u.run(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			readiness.Checks[check.Name] = result
+			if result.Status != "ok" {
+				readiness.Status = "unavailable"
+			}
+		}(check)
+	}
+	wg.Wait()
+	return readiness
+}
+
+func (u *healthUsecase) run(ctx context.Context, check HealthCheck) CheckResult {
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = u.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	// contextを見ない確認でも時間制限で返せるよう、結果は別のゴルーチンから受け取る
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := CheckResult{Status: "ok", DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+// usecase/image_usecase.go
+
+package usecase
+
+import (
+	"bytes"
+	"comic-summaries/imagecache"
+	"comic-summaries/imagepipeline"
+	"comic-summaries/imagestore"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+)
+
+// MaxImageDimension は縮小して返す画像の幅・高さの上限です。
+const MaxImageDimension = 2000
+
+// maxOriginalBytes は縮小のために読み込む元画像のサイズの上限です。
+const maxOriginalBytes = 20 << 20
+
+// ErrInvalidImageSize は縮小後の大きさの指定が正しくないことを表します。
+var ErrInvalidImageSize = errors.New("invalid image size")
+
+// ImageResize は縮小後の大きさの指定です。0の辺は縦横比から決めます。
+type ImageResize struct {
+	Width  int
+	Height int
+	Fit    string
+}
+
+// ResizedImage は縮小した画像です。ETagは同じ元画像と指定に対して常に同じ値になります。
+type ResizedImage struct {
+	Data        []byte
+	ContentType string
+	ETag        string
+}
+
+type IImageUsecase interface {
+	GetOriginal(ctx context.Context, key string) (*imagestore.Object, error)
+	GetResized(ctx context.Context, key string, resize ImageResize) (*ResizedImage, error)
+}
+
+type imageUsecase struct {
+	store imagestore.ImageStore
+	cache *imagecache.Cache
+	// slots は同時に縮小する画像の数を制限します。
+	slots chan struct{}
+}
+
+// NewImageUsecase は新しいimageUsecaseインスタンスを生成します。cacheがnilの場合は毎回縮小します。
+func NewImageUsecase(store imagestore.ImageStore, cache *imagecache.Cache) IImageUsecase {
+	return &imageUsecase{
+		store: store,
+		cache: cache,
+		slots: make(chan struct{}, runtime.NumCPU()),
+	}
+}
+
+func (u *imageUsecase) GetOriginal(ctx context.Context, key string) (*imagestore.Object, error) {
+	return u.store.Get(ctx, key)
+}
+
+func (u *imageUsecase) GetResized(ctx context.Context, key string, resize ImageResize) (*ResizedImage, error) {
+	if resize.Fit == "" {
+		resize.Fit = imagepipeline.FitContain
+	}
+	if err := validateResize(resize); err != nil {
+		return nil, err
+	}
+
+	// キャッシュのキーは元画像のキーと指定から作るため、同じ指定には同じETagを返せる
+	sum := sha256.Sum256([]byte(key + "\x00" + strconv.Itoa(resize.Width) + "x" + strconv.Itoa(resize.Height) + "\x00" + resize.Fit))
+	cacheKey := hex.EncodeToString(sum[:]) + ".jpg"
+	resized := &ResizedImage{ContentType: "image/jpeg", ETag: hex.EncodeToString(sum[:16])}
+	if u.cache != nil {
+		if data, ok := u.cache.Get(cacheKey); ok {
+			resized.Data = data
+			return resized, nil
+		}
+	}
+
+	object, err := u.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	data, err := io.ReadAll(io.LimitReader(object, maxOriginalBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxOriginalBytes {
+		return nil, fmt.Errorf("%w: original larger than %d bytes", imagepipeline.ErrUnsupported, maxOriginalBytes)
+	}
+
+	select {
+	case u.slots <- struct{}{}:
+		defer func() { <-u.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	img, _, err := imagepipeline.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	scaled, err := imagepipeline.Resize(img, resize.Width, resize.Height, resize.Fit)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := (imagepipeline.JPEGEncoder{}).Encode(&buf, scaled); err != nil {
+		return nil, err
+	}
+	resized.Data = buf.Bytes()
+
+	if u.cache != nil {
+		if err := u.cache.Put(cacheKey, resized.Data); err != nil {
+			return nil, err
+		}
+	}
+	return resized, nil
+}
+
+// validateResize は大きすぎる画像や不明なfitを受け付けないようにします。
+func validateResize(resize ImageResize) error {
+	if resize.Width < 0 || resize.Height < 0 || (resize.Width == 0 && resize.Height == 0) {
+		return fmt.Errorf("%w: width or height is required", ErrInvalidImageSize)
+	}
+	if resize.Width > MaxImageDimension || resize.Height > MaxImageDimension {
+		return fmt.Errorf("%w: width and height must be at most %d", ErrInvalidImageSize, MaxImageDimension)
+	}
+	if !imagepipeline.ValidFit(resize.Fit) {
+		return fmt.Errorf("%w: unknown fit %q", ErrInvalidImageSize, resize.Fit)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// usecase/regenerate_usecase.go
+
+package usecase
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/generator"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoCriteria は条件を指定せずに全件を再生成しようとしたことを表します。
+var ErrNoCriteria = errors.New("no criteria given; specify at least one or select every comic explicitly")
+
+// RegenerateCriteria は要約を再生成する漫画の条件です。指定した条件を全て満たす漫画が対象になります。
+// 誤って全件を再生成しないよう、全件を対象にするにはAllを指定する必要があります。
+type RegenerateCriteria struct {
+	// All がtrueであれば、他の条件を指定しなくても全件を対象にします。
+	All bool
+	// IDs が空でなければ、含まれるIDの漫画に限ります。
+	IDs []int
+	// OlderThan が0より大きければ、最後の更新からそれ以上経過した漫画に限ります。更新日時のないものは古いものとして扱います。
+	OlderThan time.Duration
+	// PromptVersionNot が空でなければ、それ以外のプロンプトで生成された漫画に限ります。
+	PromptVersionNot string
+	// Genre が空でなければ、ジャンルにその文字列を含む漫画に限ります。
+	Genre string
+}
+
+// Empty は絞り込む条件が1つも指定されていないかを返します。
+func (c RegenerateCriteria) Empty() bool {
+	return len(c.IDs) == 0 && c.OlderThan <= 0 && c.PromptVersionNot == "" && c.Genre == ""
+}
+
+// Matches は漫画が条件を満たすかを判定します。
+func (c RegenerateCriteria) Matches(comic *entity.Comic, now time.Time) bool {
+	if len(c.IDs) > 0 {
+		found := false
+		for _, id := range c.IDs {
+			if id == comic.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.OlderThan > 0 && !comic.UpdatedAt.IsZero() && now.Sub(comic.UpdatedAt) < c.OlderThan {
+		return false
+	}
+	if c.PromptVersionNot != "" && comic.PromptVersion == c.PromptVersionNot {
+		return false
+	}
+	if c.Genre != "" && !strings.Contains(comic.Genre, c.Genre) {
+		return false
+	}
+	return true
+}
+
+// Regeneration は1件の再生成の結果です。Candidateは保存前の新しい内容です。
+type Regeneration struct {
+	Current   *entity.Comic
+	Candidate *entity.Comic
+	Changes   []entity.FieldChange
+	Err       error
+}
+
+type IRegenerateUsecase interface {
+	Select(ctx context.Context, criteria RegenerateCriteria) ([]*entity.Comic, error)
+	Regenerate(ctx context.Context, comic *entity.Comic) Regeneration
+	Apply(ctx context.Context, regeneration Regeneration, author string) error
+}
+
+type regenerateUsecase struct {
+	comicUsecase IComicUsecase
+	generator    generator.SummaryGenerator
+}
+
+// NewRegenerateUsecase は新しいregenerateUsecaseインスタンスを生成します。生成はSummaryGeneratorにのみ依存します。
+func NewRegenerateUsecase(cu IComicUsecase, gen generator.SummaryGenerator) IRegenerateUsecase {
+	return &regenerateUsecase{
+		comicUsecase: cu,
+		generator:    gen,
+	}
+}
+
+// Select は条件を満たす漫画をID順に返します。条件がなくAllも指定されていない場合はErrNoCriteriaを返します。
+func (u *regenerateUsecase) Select(ctx context.Context, criteria RegenerateCriteria) ([]*entity.Comic, error) {
+	if criteria.Empty() && !criteria.All {
+		return nil, ErrNoCriteria
+	}
+	comics, err := u.comicUsecase.ListEveryComic(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	selected := make([]*entity.Comic, 0)
+	for _, comic := range comics {
+		if criteria.Matches(comic, now) {
+			selected = append(selected, comic)
+		}
+	}
+	entity.SortComicsByID(selected)
+	return selected, nil
+}
+
+// Regenerate は漫画の要約を生成し直し、現在の内容との差分を返します。保存はしません。
+func (u *regenerateUsecase) Regenerate(ctx context.Context, comic *entity.Comic) Regeneration {
+	summary, err := u.generator.Generate(ctx, comic.Title)
+	if err != nil {
+		return Regeneration{Current: comic, Err: err}
+	}
+
+	candidate := *comic
+	candidate.Synopsis = summary.Synopsis
+	candidate.Attraction = summary.Attraction
+	candidate.Spoilers = summary.Spoilers
+	candidate.Genre = summary.Genre
+	candidate.Characters = summary.Characters
+	candidate.Model = summary.Usage.Model
+	candidate.PromptVersion = summary.PromptVersion
+
+	return Regeneration{
+		Current:   comic,
+		Candidate: &candidate,
+		Changes:   entity.Diff(comic, &candidate),
+	}
+}
+
+// Apply は再生成した内容を保存します。以前の内容はリビジョンとして履歴に残ります。
+// LLMが生成した内容をレビューせずに公開しないよう、公開中の漫画は公開中の要約を残したまま、再生成した要約を
+// レビュー待ちの要約(Pending)として保存します。レビューで承認されると公開中の要約が差し替わります。
+// 公開前の漫画は公開されていないため、レビュー状態を変えずに要約を書き換えます。
+func (u *regenerateUsecase) Apply(ctx context.Context, regeneration Regeneration, author string) error {
+	if regeneration.Err != nil || regeneration.Candidate == nil {
+		return fmt.Errorf("comic %d has no regenerated summary", regeneration.Current.ID)
+	}
+
+	candidate := *regeneration.Candidate
+	reason := fmt.Sprintf("regenerated with %s (prompt %s)", candidate.Model, candidate.PromptVersion)
+	if regeneration.Current.Published() {
+		comic := *regeneration.Current
+		comic.Pending = entity.NewPendingSummary(&candidate)
+		return u.comicUsecase.SaveComic(ctx, &comic, author, reason+", awaiting review")
+	}
+
+	candidate.Touch(entity.SourceLLM, time.Now())
+	return u.comicUsecase.SaveComic(ctx, &candidate, author, reason)
+}
@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/generator"
+	"comic-summaries/repository"
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubGenerator は全てのタイトルに同じ要約を返します。
+type stubGenerator struct {
+	summary generator.Summary
+}
+
+func (g stubGenerator) Generate(ctx context.Context, title string) (*generator.Summary, error) {
+	summary := g.summary
+	return &summary, nil
+}
+
+func TestSelectRequiresCriteria(t *testing.T) {
+	ctx := context.Background()
+	comicRepo := repository.NewMemoryComicRepository([]entity.Comic{{ID: 1, Title: "漫画", Genre: "少年漫画"}, {ID: 2, Title: "別の漫画"}})
+	u := NewRegenerateUsecase(NewComicUsecase(comicRepo, repository.NewMemoryRevisionRepository()), stubGenerator{})
+
+	if _, err := u.Select(ctx, RegenerateCriteria{}); !errors.Is(err, ErrNoCriteria) {
+		t.Errorf("Select without criteria: err = %v, want ErrNoCriteria", err)
+	}
+	comics, err := u.Select(ctx, RegenerateCriteria{All: true})
+	if err != nil || len(comics) != 2 {
+		t.Errorf("Select with All = %d comics, %v; want 2", len(comics), err)
+	}
+	comics, err = u.Select(ctx, RegenerateCriteria{Genre: "少年"})
+	if err != nil || len(comics) != 1 || comics[0].ID != 1 {
+		t.Errorf("Select by genre = %v, %v; want comic 1", comics, err)
+	}
+}
+
+func TestApplyDoesNotPublishRegeneratedSummaries(t *testing.T) {
+	tests := []struct {
+		status      string
+		wantPending bool // 公開中の要約を残し、再生成した要約はレビュー待ちにする
+	}{
+		{entity.StatusPublished, true},
+		{"", true}, // レビュー導入前のデータは公開中
+		{entity.StatusDraft, false},
+		{entity.StatusInReview, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			ctx := context.Background()
+			comicRepo := repository.NewMemoryComicRepository([]entity.Comic{{ID: 1, Title: "漫画", Synopsis: "元のあらすじ", Source: entity.SourceAdmin, Status: tt.status}})
+			comicUsecase := NewComicUsecase(comicRepo, repository.NewMemoryRevisionRepository())
+			u := NewRegenerateUsecase(comicUsecase, stubGenerator{generator.Summary{Synopsis: "新しいあらすじ", PromptVersion: "v2"}})
+
+			comic, err := comicRepo.FindByID(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			regeneration := u.Regenerate(ctx, comic)
+			if err := u.Apply(ctx, regeneration, "editor"); err != nil {
+				t.Fatal(err)
+			}
+
+			saved, err := comicRepo.FindByID(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Status != tt.status {
+				t.Errorf("status = %q, want %q", saved.Status, tt.status)
+			}
+			if !tt.wantPending {
+				if saved.Synopsis != "新しいあらすじ" || saved.Source != entity.SourceLLM || saved.Pending != nil {
+					t.Errorf("saved comic = %+v, want the new synopsis", saved)
+				}
+				return
+			}
+
+			if saved.Synopsis != "元のあらすじ" || saved.Source != entity.SourceAdmin {
+				t.Errorf("saved comic = %+v, want the published synopsis kept", saved)
+			}
+			if saved.Pending == nil || saved.Pending.Synopsis != "新しいあらすじ" || saved.Pending.PromptVersion != "v2" {
+				t.Errorf("Pending = %+v, want the regenerated summary", saved.Pending)
+			}
+			public, err := comicUsecase.GetPublishedComicByID(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if public == nil || public.Synopsis != "元のあらすじ" || public.Pending != nil {
+				t.Errorf("public comic = %+v, want the published synopsis without the pending one", public)
+			}
+		})
+	}
+}
+
+func TestReviewRegeneratedSummary(t *testing.T) {
+	pending := &entity.PendingSummary{Synopsis: "新しいあらすじ", Genre: "青年漫画", PromptVersion: "v2"}
+	tests := []struct {
+		name    string
+		review  func(u IReviewUsecase) (*entity.Comic, error)
+		want    string // 公開される要約
+		comment string
+	}{
+		{
+			name: "approve",
+			review: func(u IReviewUsecase) (*entity.Comic, error) {
+				return u.Approve(context.Background(), 1, "reviewer", "")
+			},
+			want: "新しいあらすじ",
+		},
+		{
+			name: "reject",
+			review: func(u IReviewUsecase) (*entity.Comic, error) {
+				return u.Reject(context.Background(), 1, "reviewer", "事実と違う")
+			},
+			want:    "元のあらすじ",
+			comment: "事実と違う",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, comicRepo, _ := newReviewUsecase(entity.Comic{ID: 1, Title: "漫画", Synopsis: "元のあらすじ", Status: entity.StatusPublished, Pending: pending})
+
+			queue, err := u.ListQueue(context.Background(), entity.StatusInReview)
+			if err != nil || !equalInts(comicIDs(queue), []int{1}) {
+				t.Fatalf("ListQueue(in_review) = %v, %v; want the comic awaiting review", comicIDs(queue), err)
+			}
+			if _, err := tt.review(u); err != nil {
+				t.Fatal(err)
+			}
+
+			saved, err := comicRepo.FindByID(context.Background(), "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Status != entity.StatusPublished || saved.Synopsis != tt.want || saved.Pending != nil {
+				t.Errorf("saved comic = %+v, want published with synopsis %q and no pending summary", saved, tt.want)
+			}
+			if saved.ReviewedBy != "reviewer" || saved.ReviewComment != tt.comment {
+				t.Errorf("review = %q by %q, want %q by reviewer", saved.ReviewComment, saved.ReviewedBy, tt.comment)
+			}
+			if queue, _ := u.ListQueue(context.Background(), entity.StatusInReview); len(queue) != 0 {
+				t.Errorf("ListQueue(in_review) = %v after the review, want empty", comicIDs(queue))
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// usecase/review_usecase.go
+
+package usecase
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTransition は現在のレビュー状態からは実行できない操作であることを表します。
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// ErrUnknownStatus は存在しないレビュー状態が指定されたことを表します。
+var ErrUnknownStatus = errors.New("unknown status")
+
+// ErrCommentRequired は差し戻しにコメントがないことを表します。
+var ErrCommentRequired = errors.New("review comment is required")
+
+type IReviewUsecase interface {
+	ListQueue(ctx context.Context, status string) ([]*entity.Comic, error)
+	Submit(ctx context.Context, id int, reviewer string) (*entity.Comic, error)
+	Approve(ctx context.Context, id int, reviewer string, comment string) (*entity.Comic, error)
+	Reject(ctx context.Context, id int, reviewer string, comment string) (*entity.Comic, error)
+}
+
+type reviewUsecase struct {
+	comicUsecase IComicUsecase
+	comicRepo    repository.IComicRepository
+}
+
+// NewReviewUsecase は新しいreviewUsecaseインスタンスを生成します。状態の変更はSaveComicで保存するため、リビジョンとして残ります。
+func NewReviewUsecase(cu IComicUsecase, repo repository.IComicRepository) IReviewUsecase {
+	return &reviewUsecase{
+		comicUsecase: cu,
+		comicRepo:    repo,
+	}
+}
+
+// ListQueue は指定したレビュー状態の漫画を返します。
+func (u *reviewUsecase) ListQueue(ctx context.Context, status string) ([]*entity.Comic, error) {
+	if !entity.ValidStatus(status) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, status)
+	}
+	return u.comicRepo.FindByStatus(ctx, status)
+}
+
+// Submit は下書きまたは差し戻された漫画をレビュー待ちにします。
+func (u *reviewUsecase) Submit(ctx context.Context, id int, reviewer string) (*entity.Comic, error) {
+	return u.transition(ctx, id, []string{entity.StatusDraft, entity.StatusRejected}, entity.StatusInReview, reviewer, "")
+}
+
+// Approve はレビュー待ちの漫画を公開します。公開中の漫画に再生成した要約が届いている場合は、その要約に差し替えます。
+func (u *reviewUsecase) Approve(ctx context.Context, id int, reviewer string, comment string) (*entity.Comic, error) {
+	return u.transition(ctx, id, []string{entity.StatusInReview}, entity.StatusPublished, reviewer, comment)
+}
+
+// Reject はレビュー待ちの漫画を差し戻します。差し戻しの理由をコメントで残す必要があります。
+// 公開中の漫画に再生成した要約が届いている場合は、その要約を破棄して公開中の要約を残します。
+func (u *reviewUsecase) Reject(ctx context.Context, id int, reviewer string, comment string) (*entity.Comic, error) {
+	if strings.TrimSpace(comment) == "" {
+		return nil, ErrCommentRequired
+	}
+	return u.transition(ctx, id, []string{entity.StatusInReview}, entity.StatusRejected, reviewer, comment)
+}
+
+// transition は漫画の状態がfromのいずれかである場合にtoへ変更して保存します。
+func (u *reviewUsecase) transition(ctx context.Context, id int, from []string, to string, reviewer string, comment string) (*entity.Comic, error) {
+	comic, err := u.comicRepo.FindByID(ctx, strconv.Itoa(id))
+	if err != nil {
+		return nil, err
+	}
+	if comic == nil {
+		return nil, ErrComicNotFound
+	}
+	if comic.Published() && comic.Pending != nil && (to == entity.StatusPublished || to == entity.StatusRejected) {
+		return u.reviewPending(ctx, comic, to == entity.StatusPublished, reviewer, comment)
+	}
+
+	current := comic.Status
+	if current == "" {
+		current = entity.StatusPublished
+	}
+	allowed := false
+	for _, status := range from {
+		if current == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, current, to)
+	}
+
+	comic.Status = to
+	comic.ReviewComment = comment
+	comic.ReviewedBy = reviewer
+
+	reason := fmt.Sprintf("review: %s -> %s", current, to)
+	if comment != "" {
+		reason += ": " + comment
+	}
+	if err := u.comicUsecase.SaveComic(ctx, comic, reviewer, reason); err != nil {
+		return nil, err
+	}
+	return comic, nil
+}
+
+// reviewPending は公開中の漫画に届いている再生成した要約を、承認する場合は公開し、差し戻す場合は破棄します。
+// どちらの場合も漫画は公開したままです。
+func (u *reviewUsecase) reviewPending(ctx context.Context, comic *entity.Comic, approve bool, reviewer string, comment string) (*entity.Comic, error) {
+	reason := "review: regenerated summary rejected"
+	if approve {
+		comic.ApplyPending()
+		comic.Touch(entity.SourceLLM, time.Now())
+		reason = "review: regenerated summary published"
+	}
+	comic.Pending = nil
+	comic.ReviewComment = comment
+	comic.ReviewedBy = reviewer
+
+	if comment != "" {
+		reason += ": " + comment
+	}
+	if err := u.comicUsecase.SaveComic(ctx, comic, reviewer, reason); err != nil {
+		return nil, err
+	}
+	return comic, nil
+}
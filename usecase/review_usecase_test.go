@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/repository"
+	"context"
+	"errors"
+	"testing"
+)
+
+func newReviewUsecase(comics ...entity.Comic) (IReviewUsecase, repository.IComicRepository, repository.IRevisionRepository) {
+	comicRepo := repository.NewMemoryComicRepository(comics)
+	revisionRepo := repository.NewMemoryRevisionRepository()
+	return NewReviewUsecase(NewComicUsecase(comicRepo, revisionRepo), comicRepo), comicRepo, revisionRepo
+}
+
+func TestReviewTransitions(t *testing.T) {
+	type action func(u IReviewUsecase) (*entity.Comic, error)
+	submit := func(u IReviewUsecase) (*entity.Comic, error) { return u.Submit(context.Background(), 1, "editor") }
+	approve := func(u IReviewUsecase) (*entity.Comic, error) {
+		return u.Approve(context.Background(), 1, "reviewer", "")
+	}
+	reject := func(u IReviewUsecase) (*entity.Comic, error) {
+		return u.Reject(context.Background(), 1, "reviewer", "ネタバレが短い")
+	}
+
+	tests := []struct {
+		name    string
+		from    string
+		action  action
+		want    string // 遷移後の状態。空の場合は遷移できない
+		comment string
+	}{
+		{"submit a draft", entity.StatusDraft, submit, entity.StatusInReview, ""},
+		{"resubmit a rejected comic", entity.StatusRejected, submit, entity.StatusInReview, ""},
+		{"approve", entity.StatusInReview, approve, entity.StatusPublished, ""},
+		{"reject", entity.StatusInReview, reject, entity.StatusRejected, "ネタバレが短い"},
+
+		{"submit a comic in review", entity.StatusInReview, submit, "", ""},
+		{"submit a published comic", entity.StatusPublished, submit, "", ""},
+		{"approve a draft", entity.StatusDraft, approve, "", ""},
+		{"approve a rejected comic", entity.StatusRejected, approve, "", ""},
+		{"approve a published comic", entity.StatusPublished, approve, "", ""},
+		{"approve a comic without status", "", approve, "", ""},
+		{"reject a draft", entity.StatusDraft, reject, "", ""},
+		{"reject a published comic", entity.StatusPublished, reject, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, comicRepo, revisionRepo := newReviewUsecase(entity.Comic{ID: 1, Title: "漫画", Status: tt.from})
+
+			comic, err := tt.action(u)
+			saved, findErr := comicRepo.FindByID(context.Background(), "1")
+			if findErr != nil {
+				t.Fatal(findErr)
+			}
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Fatalf("err = %v, want ErrInvalidTransition", err)
+				}
+				if saved.Status != tt.from {
+					t.Errorf("status changed to %q by an invalid transition", saved.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if comic.Status != tt.want || saved.Status != tt.want {
+				t.Errorf("status = %q (saved %q), want %q", comic.Status, saved.Status, tt.want)
+			}
+			if saved.ReviewComment != tt.comment {
+				t.Errorf("ReviewComment = %q, want %q", saved.ReviewComment, tt.comment)
+			}
+			// 状態の変更もリビジョンに残る
+			revisions, err := revisionRepo.FindByComicID(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revisions) == 0 || revisions[len(revisions)-1].Comic.Status != tt.want {
+				t.Errorf("revisions = %+v, want the transition recorded", revisions)
+			}
+		})
+	}
+}
+
+func TestRejectRequiresComment(t *testing.T) {
+	u, comicRepo, _ := newReviewUsecase(entity.Comic{ID: 1, Title: "漫画", Status: entity.StatusInReview})
+
+	if _, err := u.Reject(context.Background(), 1, "reviewer", "  "); !errors.Is(err, ErrCommentRequired) {
+		t.Errorf("err = %v, want ErrCommentRequired", err)
+	}
+	if saved, _ := comicRepo.FindByID(context.Background(), "1"); saved.Status != entity.StatusInReview {
+		t.Errorf("status = %q, want in_review", saved.Status)
+	}
+}
+
+func TestReviewErrors(t *testing.T) {
+	u, _, _ := newReviewUsecase()
+	if _, err := u.Approve(context.Background(), 1, "reviewer", ""); !errors.Is(err, ErrComicNotFound) {
+		t.Errorf("Approve of a missing comic: err = %v, want ErrComicNotFound", err)
+	}
+	if _, err := u.ListQueue(context.Background(), "archived"); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("ListQueue(archived): err = %v, want ErrUnknownStatus", err)
+	}
+}
+
+func TestListQueue(t *testing.T) {
+	u, _, _ := newReviewUsecase(
+		entity.Comic{ID: 1, Title: "公開中", Status: entity.StatusPublished},
+		entity.Comic{ID: 2, Title: "レビュー待ち", Status: entity.StatusInReview},
+		entity.Comic{ID: 3, Title: "状態なし"},
+		entity.Comic{ID: 4, Title: "レビュー待ち2", Status: entity.StatusInReview},
+	)
+	tests := []struct {
+		status string
+		want   []int
+	}{
+		{entity.StatusInReview, []int{2, 4}},
+		// 状態のない漫画は公開中として扱う
+		{entity.StatusPublished, []int{1, 3}},
+		{entity.StatusDraft, []int{}},
+	}
+	for _, tt := range tests {
+		comics, err := u.ListQueue(context.Background(), tt.status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := comicIDs(comics); !equalInts(got, tt.want) {
+			t.Errorf("ListQueue(%s) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func comicIDs(comics []*entity.Comic) []int {
+	ids := make([]int, 0, len(comics))
+	for _, comic := range comics {
+		ids = append(ids, comic.ID)
+	}
+	return ids
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"comic-summaries/entity"
+	"comic-summaries/repository"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRollbackKeepsReviewStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		old    string // 戻す先のリビジョンの状態
+		status string // 現在の状態
+	}{
+		{"rejected content does not get published", entity.StatusRejected, entity.StatusPublished},
+		{"draft content does not get published", entity.StatusDraft, entity.StatusPublished},
+		{"a live comic is not unpublished", entity.StatusPublished, entity.StatusInReview},
+		{"a comic without status stays published", entity.StatusDraft, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			comicRepo := repository.NewMemoryComicRepository([]entity.Comic{{ID: 1, Title: "漫画", Synopsis: "古いあらすじ", Status: tt.old, ReviewComment: "古いコメント", ReviewedBy: "old reviewer", Pending: &entity.PendingSummary{Synopsis: "レビュー済みの再生成"}}})
+			revisionRepo := repository.NewMemoryRevisionRepository()
+			cu := NewComicUsecase(comicRepo, revisionRepo)
+			if err := cu.SaveComic(ctx, &entity.Comic{ID: 1, Title: "漫画", Synopsis: "新しいあらすじ", Status: tt.status, ReviewComment: "承認", ReviewedBy: "reviewer"}, "editor", "edit"); err != nil {
+				t.Fatal(err)
+			}
+
+			comic, err := NewRevisionUsecase(cu, revisionRepo).Rollback(ctx, 1, 1, "editor", "revert")
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved, err := comicRepo.FindByID(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []*entity.Comic{comic, saved} {
+				if c.Synopsis != "古いあらすじ" {
+					t.Errorf("Synopsis = %q, want the rolled back content", c.Synopsis)
+				}
+				if c.Status != tt.status || c.ReviewComment != "承認" || c.ReviewedBy != "reviewer" {
+					t.Errorf("review = %q/%q/%q, want the current %q/承認/reviewer", c.Status, c.ReviewComment, c.ReviewedBy, tt.status)
+				}
+				if c.Pending != nil {
+					t.Errorf("Pending = %+v, want the old revision's pending summary dropped", c.Pending)
+				}
+			}
+
+			revisions, err := revisionRepo.FindByComicID(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revisions) != 3 || revisions[2].Reason != "rollback to revision 1: revert" {
+				t.Errorf("revisions = %+v, want the rollback recorded as revision 3", revisions)
+			}
+		})
+	}
+}
+
+func TestRollbackToMissingRevision(t *testing.T) {
+	ctx := context.Background()
+	comicRepo := repository.NewMemoryComicRepository([]entity.Comic{{ID: 1, Title: "漫画"}})
+	revisionRepo := repository.NewMemoryRevisionRepository()
+	cu := NewComicUsecase(comicRepo, revisionRepo)
+	if err := cu.SaveComic(ctx, &entity.Comic{ID: 1, Title: "漫画2"}, "editor", "edit"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewRevisionUsecase(cu, revisionRepo).Rollback(ctx, 1, 5, "editor", ""); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("err = %v, want ErrRevisionNotFound", err)
+	}
+}